@@ -0,0 +1,183 @@
+package arrowloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/arrowutils"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+)
+
+var (
+	// The IPC file format, which Feather v2 also uses, starts with this magic. Anything else is
+	// read as the IPC stream format.
+	fileMagic = []byte("ARROW1")
+	// Feather v1 files predate the IPC format and can't be read.
+	featherV1Magic = []byte("FEA1")
+)
+
+// recordReader is what the IPC file and stream readers have in common.
+type recordReader interface {
+	Schema() *arrow.Schema
+	next() (arrow.RecordBatch, error)
+}
+
+type fileReader struct {
+	*ipc.FileReader
+	idx int
+}
+
+func (r *fileReader) next() (arrow.RecordBatch, error) {
+	if r.idx == r.NumRecords() {
+		return nil, io.EOF
+	}
+	r.idx++
+	return r.RecordBatch(r.idx - 1)
+}
+
+type streamReader struct {
+	*ipc.Reader
+}
+
+func (r *streamReader) next() (arrow.RecordBatch, error) {
+	if r.Next() {
+		return r.RecordBatch(), nil
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type ArrowLoader struct {
+	maxConcurrency int
+
+	typeSetting string
+
+	filesList []string
+
+	db *dbv2.DB
+}
+
+func New(files []string, db *dbv2.DB, concurrency int, t string) *ArrowLoader {
+	return &ArrowLoader{
+		maxConcurrency: concurrency,
+		typeSetting:    t,
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (a *ArrowLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, failed int64
+	start := time.Now()
+
+	err := shared.RunInParallel(a.maxConcurrency, a.filesList, func(file string) error {
+		var err error
+
+		name := shared.GetTableName(file)
+		defer func() {
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = a.db.DeleteTable(name)
+			}
+		}()
+
+		rowsInserted, err := a.load(ctx, file, name)
+		if err != nil {
+			printError(file, name, err)
+			return err
+		}
+		atomic.AddInt64(&totalRowsInserted, rowsInserted)
+		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s\n",
+			shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file)
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"ARROW", len(a.filesList), len(a.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+func (a *ArrowLoader) load(ctx context.Context, file, name string) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	rr, release, err := openRecordReader(f)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	// The table is created from the schema stored in the file, dictionary encoded columns
+	// taking the type of their values.
+	colsTypes, cols := arrowutils.ColumnTypes(rr.Schema(), a.typeSetting)
+
+	// Ensure the table exists or create it if necessary.
+	if err = a.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return 0, err
+	}
+
+	return csv2.LoadTextStream(ctx, name, a.db, cols, func(w io.Writer) error {
+		bw := bufio.NewWriterSize(w, 64*1024)
+		for {
+			rec, err := rr.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err = arrowutils.WriteRecordText(bw, rec); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+// openRecordReader picks the IPC file or stream reader from the magic at the start of the file.
+func openRecordReader(f *os.File) (recordReader, func(), error) {
+	magic := make([]byte, len(fileMagic))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	if bytes.HasPrefix(magic[:n], featherV1Magic) {
+		return nil, nil, fmt.Errorf("feather v1 files aren't supported, rewrite them as feather v2 (arrow ipc)")
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.Equal(magic[:n], fileMagic) {
+		fr, err := ipc.NewFileReader(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &fileReader{FileReader: fr}, func() { fr.Close() }, nil
+	}
+	sr, err := ipc.NewReader(bufio.NewReaderSize(f, 64*1024))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &streamReader{Reader: sr}, sr.Release, nil
+}
+
+func printError(f, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="ARROW" msg="unable to load" file=%q name=%q error=%q`+"\n", f, name, err.Error())
+}
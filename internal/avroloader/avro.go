@@ -0,0 +1,339 @@
+package avroloader
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+type column struct {
+	name   string
+	pgType string
+	// Only set for decimal columns, used to print *big.Rat values.
+	scale int
+	// Nullable unions of complex types are decoded wrapped in a single key map, keyed by this name.
+	unionKey string
+	// Unit of local-timestamp-millis and local-timestamp-micros columns, whose values may
+	// come as the raw count since the epoch.
+	localUnit time.Duration
+}
+
+type AvroLoader struct {
+	maxConcurrency int
+
+	typeSetting string
+
+	filesList []string
+
+	db *dbv2.DB
+}
+
+func New(files []string, db *dbv2.DB, concurrency int, t string) *AvroLoader {
+	return &AvroLoader{
+		maxConcurrency: concurrency,
+		typeSetting:    t,
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (a *AvroLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, failed int64
+	start := time.Now()
+
+	err := shared.RunInParallel(a.maxConcurrency, a.filesList, func(file string) error {
+		var err error
+
+		name := shared.GetTableName(file)
+		defer func() {
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = a.db.DeleteTable(name)
+			}
+		}()
+
+		rowsInserted, err := a.load(ctx, file, name)
+		if err != nil {
+			printError(file, name, err)
+			return err
+		}
+		atomic.AddInt64(&totalRowsInserted, rowsInserted)
+		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s\n",
+			shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file)
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"AVRO", len(a.filesList), len(a.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+func (a *AvroLoader) load(ctx context.Context, file, name string) (int64, error) {
+	r, err := reader.NewFileGzipReaderContext(ctx, file)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	dec, err := ocf.NewDecoder(r)
+	if err != nil {
+		return 0, err
+	}
+	// The writer schema embedded in the file header decides the table columns.
+	cols, err := columnsFromSchema(dec.Schema(), a.typeSetting)
+	if err != nil {
+		return 0, err
+	}
+
+	colsTypes := make([]string, len(cols))
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		colsTypes[i] = strconv.Quote(col.name) + " " + col.pgType
+		names[i] = col.name
+	}
+	// Ensure the table exists or create it if necessary.
+	if err = a.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return 0, err
+	}
+
+	return csv2.LoadTextStream(ctx, name, a.db, names, func(w io.Writer) error {
+		return convertAvroToText(w, dec, cols)
+	})
+}
+
+// convertAvroToText writes the records in the text format of COPY, with \N for nulls, so
+// empty strings aren't loaded as nulls.
+func convertAvroToText(w io.Writer, dec *ocf.Decoder, cols []column) error {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	rec := make(map[string]any, len(cols))
+	for dec.HasNext() {
+		clear(rec)
+		if err := dec.Decode(&rec); err != nil {
+			return err
+		}
+		for i, col := range cols {
+			if i > 0 {
+				bw.WriteByte('\t')
+			}
+			if rec[col.name] == nil {
+				bw.WriteString(csvutils.CopyTextNull)
+				continue
+			}
+			val, err := toString(rec[col.name], col)
+			if err != nil {
+				return err
+			}
+			csvutils.CopyTextEscaper.WriteString(bw, val)
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := dec.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Layout of local timestamps, which go to TIMESTAMP columns without a zone.
+const localTimestampLayout = "2006-01-02 15:04:05.999999"
+
+func columnsFromSchema(schema avro.Schema, typeSetting string) ([]column, error) {
+	rs, ok := schema.(*avro.RecordSchema)
+	if !ok {
+		return nil, fmt.Errorf("writer schema must be a record, got %q", schema.Type())
+	}
+
+	var cols []column
+	for _, f := range rs.Fields() {
+		col := column{name: f.Name(), pgType: dbv2.Text, unionKey: nullableUnionKey(f.Type()), localUnit: localTimestampUnit(f.Type())}
+		if typeSetting != shared.AllText {
+			col.pgType, col.scale = pgType(f.Type())
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// pgType maps an Avro schema to a column type. Nullable unions take the type of their
+// non-null branch; any other union and all complex types are stored as JSONB.
+func pgType(s avro.Schema) (string, int) {
+	var logical avro.LogicalSchema
+	if ls, ok := s.(avro.LogicalTypeSchema); ok {
+		logical = ls.Logical()
+	}
+
+	switch s.Type() {
+	case avro.Ref:
+		return pgType(s.(*avro.RefSchema).Schema())
+	case avro.Union:
+		us := s.(*avro.UnionSchema)
+		if !us.Nullable() {
+			return dbv2.Jsonb, 0
+		}
+		_, typ := us.Indices()
+		return pgType(us.Types()[typ])
+	case avro.Record, avro.Array, avro.Map:
+		return dbv2.Jsonb, 0
+	case avro.Boolean:
+		return dbv2.Boolean, 0
+	case avro.Float:
+		return dbv2.Real, 0
+	case avro.Double:
+		return dbv2.Double, 0
+	case avro.Int:
+		if logical == nil {
+			return dbv2.Integer, 0
+		}
+		switch logical.Type() {
+		case avro.Date:
+			return dbv2.Date, 0
+		case avro.TimeMillis:
+			return dbv2.Time, 0
+		}
+		return dbv2.Integer, 0
+	case avro.Long:
+		if logical == nil {
+			return dbv2.BigInt, 0
+		}
+		switch logical.Type() {
+		case avro.TimeMicros:
+			return dbv2.Time, 0
+		case avro.TimestampMillis, avro.TimestampMicros:
+			return dbv2.TimestampTZ, 0
+		case avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+			return dbv2.Timestamp, 0
+		}
+		return dbv2.BigInt, 0
+	case avro.Bytes, avro.Fixed:
+		if dl, ok := logical.(*avro.DecimalLogicalSchema); ok {
+			return dbv2.NumericOf(int32(dl.Precision()), int32(dl.Scale())), dl.Scale()
+		}
+		return dbv2.Bytea, 0
+	case avro.String:
+		if logical != nil && logical.Type() == avro.UUID {
+			return dbv2.UUID, 0
+		}
+		return dbv2.Text, 0
+	default:
+		// enum and null
+		return dbv2.Text, 0
+	}
+}
+
+func nullableUnionKey(s avro.Schema) string {
+	us, ok := s.(*avro.UnionSchema)
+	if !ok || !us.Nullable() {
+		return ""
+	}
+	_, typ := us.Indices()
+	switch ts := us.Types()[typ].(type) {
+	case avro.NamedSchema:
+		return ts.FullName()
+	case *avro.RefSchema:
+		return ts.Schema().FullName()
+	default:
+		// Like hamba names the branch: long.local-timestamp-millis for logical types.
+		key := string(ts.Type())
+		if ls, ok := ts.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+			key += "." + string(ls.Logical().Type())
+		}
+		return key
+	}
+}
+
+// localTimestampUnit returns the unit of a local-timestamp-millis or local-timestamp-micros
+// schema, or of the non-null branch of a nullable union of one, and 0 for other schemas.
+func localTimestampUnit(s avro.Schema) time.Duration {
+	if us, ok := s.(*avro.UnionSchema); ok && us.Nullable() {
+		_, typ := us.Indices()
+		s = us.Types()[typ]
+	}
+	ls, ok := s.(avro.LogicalTypeSchema)
+	if !ok || ls.Logical() == nil || s.Type() != avro.Long {
+		return 0
+	}
+	switch ls.Logical().Type() {
+	case avro.LocalTimestampMillis:
+		return time.Millisecond
+	case avro.LocalTimestampMicros:
+		return time.Microsecond
+	}
+	return 0
+}
+
+func toString(val any, col column) (string, error) {
+	if val == nil {
+		return "", nil
+	}
+	if m, ok := val.(map[string]any); ok && col.unionKey != "" && len(m) == 1 {
+		if inner, exists := m[col.unionKey]; exists {
+			val = inner
+		}
+	}
+	if col.pgType == dbv2.Jsonb {
+		bt, err := json.Marshal(val)
+		return string(bt), err
+	}
+
+	switch t := val.(type) {
+	case string:
+		return t, nil
+	case int64:
+		if col.localUnit != 0 {
+			return time.UnixMicro(t * int64(col.localUnit/time.Microsecond)).UTC().Format(localTimestampLayout), nil
+		}
+	case []byte:
+		return `\x` + hex.EncodeToString(t), nil
+	case float32:
+		return strconv.FormatFloat(float64(t), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64), nil
+	case *big.Rat:
+		return t.FloatString(col.scale), nil
+	case time.Time:
+		if col.pgType == dbv2.Date {
+			return t.Format(time.DateOnly), nil
+		}
+		if col.localUnit != 0 {
+			// Wall clock times, which have no zone.
+			return t.Format(localTimestampLayout), nil
+		}
+		return t.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		// time-millis and time-micros are durations since midnight.
+		return time.Time{}.Add(t).Format("15:04:05.999999"), nil
+	case map[string]any, []any:
+		bt, err := json.Marshal(t)
+		return string(bt), err
+	}
+
+	// Fixed values are decoded as byte arrays of the fixed size.
+	if rv := reflect.ValueOf(val); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		bt := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(bt), rv)
+		return `\x` + hex.EncodeToString(bt), nil
+	}
+	return fmt.Sprint(val), nil
+}
+
+func printError(f, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="AVRO" msg="unable to load" file=%q name=%q error=%q`+"\n", f, name, err.Error())
+}
@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/pkg/errors"
+)
+
+// collectFiles expands the arguments into the inputs to load: glob patterns, including `**`
+// ones like exports/**/*.csv.gz, directories with --recursive, s3:// prefixes and the members
+// of archives. Inputs matching --exclude are dropped, and each input is loaded only once.
+func (c *CommandInfo) collectFiles() ([]string, error) {
+	var allFiles []string
+	for _, arg := range c.args {
+		switch {
+		case shared.IsURL(arg):
+			if shared.InputName(arg) == "" {
+				return nil, fmt.Errorf("URL %s has no path to take the table name from", arg)
+			}
+			allFiles = append(allFiles, arg)
+		case shared.IsS3URL(arg):
+			objects, err := reader.ListS3(arg)
+			if err != nil {
+				return nil, err
+			}
+			allFiles = append(allFiles, objects...)
+		case arg == shared.Stdin:
+			allFiles = append(allFiles, arg)
+		case isGlobPattern(arg):
+			result, err := doublestar.FilepathGlob(arg, doublestar.WithFilesOnly(), doublestar.WithNoHidden())
+			if err != nil {
+				return nil, errors.Wrapf(err, "glob pattern matching failed: %s", arg)
+			}
+			if len(result) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+			allFiles = append(allFiles, result...)
+		default:
+			files, err := c.dirFiles(arg)
+			if err != nil {
+				return nil, err
+			}
+			allFiles = append(allFiles, files...)
+		}
+	}
+
+	// Excluded archives aren't read, and their members can be excluded on their own too.
+	exclude := splitPatterns(c.flagsMapS[Exclude])
+	allFiles, err := excludeFiles(dedupFiles(allFiles), exclude)
+	if err != nil {
+		return nil, err
+	}
+	allFiles = dropDescriptorSet(allFiles, c.flagsMapS[DescriptorSet])
+	if allFiles, c.skippedMembers, err = expandArchives(allFiles); err != nil {
+		return nil, err
+	}
+	return excludeFiles(allFiles, exclude)
+}
+
+// dropDescriptorSet drops the --descriptor-set file from the inputs, which a pattern or
+// directory takes in when it sits next to the data, since .pb is used for both.
+func dropDescriptorSet(files []string, descriptorSet string) []string {
+	if descriptorSet == "" {
+		return files
+	}
+	desc, err := os.Stat(descriptorSet)
+	if err != nil {
+		// Reported by the protobuf loader.
+		return files
+	}
+	var kept []string
+	for _, file := range files {
+		if !shared.IsRemoteFile(file) && file != shared.Stdin {
+			if info, err := os.Stat(file); err == nil && os.SameFile(info, desc) {
+				continue
+			}
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+// isGlobPattern reports whether the argument has any of the glob syntax: *, ?, [...] or {a,b}.
+// Paths that exist as they are, like report[2024].csv, aren't patterns.
+func isGlobPattern(arg string) bool {
+	if !strings.ContainsAny(arg, "*?[{") {
+		return false
+	}
+	_, err := os.Stat(arg)
+	return err != nil
+}
+
+// dirFiles returns every file under a directory argument when --recursive is set, apart from
+// hidden ones, or the argument itself when it isn't a directory.
+func (c *CommandInfo) dirFiles(arg string) ([]string, error) {
+	info, err := os.Stat(arg)
+	if err != nil || !info.IsDir() {
+		// Files that don't exist fail when they're loaded, with the loader's error.
+		return []string{arg}, nil
+	}
+	if !c.flagsMapB[Recursive] {
+		return nil, fmt.Errorf("%s is a directory, use flag %q to load the files under it", arg, Recursive)
+	}
+
+	var files []string
+	err = filepath.WalkDir(arg, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != arg && strings.HasPrefix(d.Name(), ".") {
+			// Hidden files and directories, like .git, are skipped.
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read directory %s", arg)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files under %s", arg)
+	}
+	return files, nil
+}
+
+// dedupFiles drops the inputs given more than once, like a file matched by two patterns,
+// keeping the first one.
+func dedupFiles(files []string) []string {
+	seen := make(map[string]bool, len(files))
+	var unique []string
+	for _, file := range files {
+		key := file
+		if !shared.IsRemoteFile(file) && file != shared.Stdin {
+			key = filepath.Clean(file)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, file)
+	}
+	return unique
+}
+
+// excludeFiles drops the inputs matching any of the patterns. Patterns with a / match the
+// whole path, like exports/**/tmp/*, and ones without match the file name, like *.bak.csv.
+// Archive members and object storage keys are matched by their path inside the archive
+// or the bucket too.
+func excludeFiles(files, patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("invalid pattern %q for flag %q", pattern, Exclude)
+		}
+	}
+
+	var kept []string
+	for _, file := range files {
+		if !isExcluded(file, patterns) {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+func isExcluded(file string, patterns []string) bool {
+	name := shared.InputName(file)
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if doublestar.MatchUnvalidated(pattern, path.Base(filepath.ToSlash(name))) {
+				return true
+			}
+			continue
+		}
+		if doublestar.MatchUnvalidated(pattern, filepath.ToSlash(file)) || doublestar.MatchUnvalidated(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPatterns splits a comma separated list of glob patterns, keeping the commas of
+// alternatives like {a,b} inside their pattern.
+func splitPatterns(val string) []string {
+	var (
+		patterns []string
+		depth    int
+		start    int
+	)
+	add := func(pattern string) {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	for i, r := range val {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth = max(depth-1, 0)
+		case ',':
+			if depth == 0 {
+				add(val[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(val[start:])
+	return patterns
+}
+
+// expandArchives replaces zip archives with their members, which are read straight from the
+// archive and loaded into their own tables. Members that need random access, like parquet
+// and sqlite files, and nested archives are skipped, with a line for each in skipped. Tar
+// archives are kept as they are, since they're streamed once, member after member, by
+// streamTar.
+func expandArchives(files []string) (expanded, skipped []string, err error) {
+	for _, file := range files {
+		if !isZipArchive(file) {
+			expanded = append(expanded, file)
+			continue
+		}
+		if shared.IsRemoteFile(file) {
+			return nil, nil, fmt.Errorf("zip archives can't be streamed, since their index is at the end: %s", file)
+		}
+		members, err := reader.ListArchive(file)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to read archive %s", file)
+		}
+		for _, m := range members {
+			if reason := skipReason(m.Name); reason != "" {
+				skipped = append(skipped, skippedMember(file, m.Name, reason))
+				continue
+			}
+			member := shared.ArchiveMemberPath(file, m.Name)
+			shared.RecordFileSize(member, m.Size)
+			expanded = append(expanded, member)
+		}
+	}
+	return expanded, skipped, nil
+}
+
+func isZipArchive(file string) bool {
+	return shared.IsArchiveFile(file) && strings.HasSuffix(shared.InputName(file), ".zip")
+}
+
+// isTarArchive reports whether the file is a tar archive, compressed or not.
+func isTarArchive(file string) bool {
+	return shared.IsArchiveFile(file) && !isZipArchive(file)
+}
+
+func skippedMember(archive, member, reason string) string {
+	return fmt.Sprintf(`msg="archive member skipped" archive=%q member=%q reason=%q`, archive, member, reason)
+}
+
+// skipReason tells why an archive member can't be loaded, or returns "" when it can.
+func skipReason(name string) string {
+	switch {
+	case shared.IsArchiveFile(name):
+		return "nested archive"
+	case shared.IsParquetFile(name) || shared.IsArrowFile(name) || shared.IsExcelFile(name) || shared.IsSQLiteFile(name):
+		return "needs random access"
+	}
+	return ""
+}
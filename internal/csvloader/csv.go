@@ -1,6 +1,7 @@
 package csvloader
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
@@ -38,7 +39,7 @@ func NewCSVLoader(files []string, db *pgdb.DB, look int, t string, maxRuns int)
 
 func (c *CSVLoader) Run() error {
 	err := shared.RunInParallel(c.MaxConcurrentRuns, c.filesList, func(file string) error {
-		columnTypes, err := csvutils.FindColumnTypes(file, c.lookUpSize, &c.typeSetting)
+		columnTypes, err := csvutils.FindColumnTypes(context.Background(), file, c.lookUpSize, &c.typeSetting, csvutils.DefaultDialect)
 		if err != nil {
 			return err
 		}
@@ -102,7 +103,7 @@ func (c *CSVLoader) InsertRecordsInBatches2(path string) error {
 		return err
 	}
 	defer f.Close()
-	headers, r, err := csvutils.GetCSVHeaders(f)
+	headers, r, err := csvutils.GetCSVHeaders(f, csvutils.DefaultDialect)
 	if err != nil {
 		return err
 	}
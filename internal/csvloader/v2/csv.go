@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -12,12 +13,10 @@ import (
 	"github.com/anvesh9652/pgload/pkg/shared"
 	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
 	"github.com/anvesh9652/pgload/pkg/shared/reader"
+	"github.com/sourcegraph/conc/pool"
 )
 
-const (
-	DataFormat = "CSV"
-	Delimiter  = `','`
-)
+const DataFormat = "CSV"
 
 type CSVLoader struct {
 	MaxConcurrentRuns int
@@ -26,15 +25,22 @@ type CSVLoader struct {
 	db          *dbv2.DB
 	lookUpSize  int
 	typeSetting string
+
+	// Settings given through flags; anything left unset is picked per file.
+	dialect csvutils.Dialect
+	// Sniff the unset settings from the file contents instead of only going by the extension.
+	sniff bool
 }
 
-func NewCSVLoader(files []string, db *dbv2.DB, look int, t string, maxRuns int) *CSVLoader {
+func NewCSVLoader(files []string, db *dbv2.DB, look int, t string, maxRuns int, d csvutils.Dialect, sniff bool) *CSVLoader {
 	return &CSVLoader{
 		filesList:         files,
 		db:                db,
 		lookUpSize:        look,
 		typeSetting:       t,
 		MaxConcurrentRuns: maxRuns,
+		dialect:           d,
+		sniff:             sniff,
 	}
 }
 
@@ -53,7 +59,20 @@ func (c *CSVLoader) Run(ctx context.Context) (string, error) {
 			}
 		}()
 
-		columnTypes, err := csvutils.FindColumnTypes(file, c.lookUpSize, &c.typeSetting)
+		dialect := c.dialect.ForFile(file)
+		if c.sniff {
+			dialect, err = csvutils.DetectDialect(ctx, file, c.dialect)
+			if err != nil {
+				printError(file, name, err)
+				return err
+			}
+		}
+		if err = dialect.Validate(); err != nil {
+			printError(file, name, err)
+			return err
+		}
+
+		columnTypes, err := csvutils.FindColumnTypes(ctx, file, c.lookUpSize, &c.typeSetting, dialect)
 		if err != nil {
 			printError(file, name, err)
 			return err
@@ -67,21 +86,21 @@ func (c *CSVLoader) Run(ctx context.Context) (string, error) {
 			return err
 		}
 
-		r, err := reader.NewFileGzipReader(file)
+		r, err := reader.NewFileGzipReaderContext(ctx, file)
 		if err != nil {
 			printError(file, name, err)
 			return err
 		}
 		defer r.Close()
 
-		rowsInserted, err := LoadCSV(ctx, r, name, c.db)
+		rowsInserted, err := LoadCSV(ctx, r, name, c.db, dialect)
 		if err != nil {
 			printError(file, name, err)
 			return err
 		}
 		atomic.AddInt64(&totalRowsInserted, rowsInserted)
-		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s\n",
-			shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file)
+		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s %s\n",
+			shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file, dialect)
 		return nil
 	})
 	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
@@ -89,18 +108,63 @@ func (c *CSVLoader) Run(ctx context.Context) (string, error) {
 	return msg, err
 }
 
-func LoadCSV(ctx context.Context, r io.Reader, table string, db *dbv2.DB) (int64, error) {
-	headers, r, err := csvutils.GetCSVHeaders(r)
+func LoadCSV(ctx context.Context, r io.Reader, table string, db *dbv2.DB, d csvutils.Dialect) (int64, error) {
+	headers, r, err := csvutils.GetCSVHeaders(r, d)
 	if err != nil {
 		return 0, err
 	}
 	// Use PostgreSQL's COPY command for efficient data loading.
-	copyCmd := fmt.Sprintf(`COPY %s.%s(%s) FROM STDIN with DELIMITER %s %s`,
-		db.Schema(), table, strings.Join(headers, ", "), Delimiter, DataFormat,
+	copyCmd := fmt.Sprintf(`COPY %s.%s(%s) FROM STDIN with DELIMITER %s %s QUOTE %s ESCAPE %s`,
+		db.Schema(), table, strings.Join(headers, ", "), quoteChar(d.Delimiter), DataFormat, quoteChar(d.Quote), quoteChar(d.Escape),
 	)
 	return db.LoadIn(ctx, r, copyCmd)
 }
 
+// quoteChar returns the character as a SQL string literal.
+func quoteChar(r rune) string {
+	return "'" + strings.ReplaceAll(string(r), "'", "''") + "'"
+}
+
+// LoadStream runs write in its own goroutine and loads the CSV it produces into the table.
+// The first row written must hold the column headers.
+func LoadStream(ctx context.Context, table string, db *dbv2.DB, write func(w io.Writer) error) (int64, error) {
+	return loadPiped(write, func(r io.Reader) (int64, error) {
+		return LoadCSV(ctx, r, table, db, csvutils.DefaultDialect)
+	})
+}
+
+// LoadTextStream is like LoadStream for rows written in the text format of COPY, which
+// keeps empty strings apart from nulls. No header row is written; cols gives the column order.
+func LoadTextStream(ctx context.Context, table string, db *dbv2.DB, cols []string, write func(w io.Writer) error) (int64, error) {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = strconv.Quote(col)
+	}
+	copyCmd := fmt.Sprintf(`COPY %s.%s(%s) FROM STDIN`, db.Schema(), table, strings.Join(quoted, ", "))
+	return loadPiped(write, func(r io.Reader) (int64, error) {
+		return db.LoadIn(ctx, r, copyCmd)
+	})
+}
+
+func loadPiped(write func(w io.Writer) error, load func(r io.Reader) (int64, error)) (int64, error) {
+	pr, pw := io.Pipe()
+
+	p := pool.New().WithErrors().WithFirstError()
+	p.Go(func() error {
+		err := write(pw)
+		pw.CloseWithError(err)
+		return err
+	})
+
+	rowsInserted, err := load(pr)
+	// Unblock the writer if COPY stopped reading early.
+	pr.CloseWithError(err)
+	if werr := p.Wait(); err == nil {
+		err = werr
+	}
+	return rowsInserted, err
+}
+
 func printError(f, name string, err error) {
 	fmt.Printf(`status=FAILED data_format="CSV" msg="unable to load" file=%q name=%q error=%q`+"\n", f, name, err.Error())
 }
@@ -0,0 +1,323 @@
+package followloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+
+	"github.com/anvesh9652/concurrent-line-processor/examples/codes"
+	"github.com/buger/jsonparser"
+)
+
+// How often the files are checked for new lines.
+const pollInterval = 250 * time.Millisecond
+
+type Options struct {
+	// Longest time a new line waits before it's loaded.
+	FlushInterval time.Duration
+	// Rows loaded at once when they come in faster than the flush interval.
+	BatchRows int
+}
+
+// FollowLoader keeps CSV or JSONL files open after their end, like tail -f, and loads the
+// lines appended to them in small batches until ctx is done.
+type FollowLoader struct {
+	dataFormat  string
+	filesList   []string
+	db          *dbv2.DB
+	lookUpSize  int
+	typeSetting string
+
+	dialect csvutils.Dialect
+	sniff   bool
+
+	opts Options
+}
+
+// New returns a loader of the files in dataFormat, either shared.CSV or shared.JSONL.
+func New(dataFormat string, files []string, db *dbv2.DB, lookUp int, t string, d csvutils.Dialect, sniff bool, opts Options) *FollowLoader {
+	return &FollowLoader{
+		dataFormat:  dataFormat,
+		filesList:   files,
+		db:          db,
+		lookUpSize:  lookUp,
+		typeSetting: t,
+		dialect:     d,
+		sniff:       sniff,
+		opts:        opts,
+	}
+}
+
+func (f *FollowLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, failed int64
+	start := time.Now()
+
+	// Every file is followed at the same time, since none of them ends.
+	err := shared.RunInParallel(len(f.filesList), f.filesList, func(file string) error {
+		fl := &follower{FollowLoader: f, file: file, table: shared.GetTableName(file)}
+		err := fl.run(ctx)
+		atomic.AddInt64(&totalRowsInserted, fl.rowsInserted)
+		if err != nil {
+			// The batches loaded before stay, so the table isn't dropped.
+			atomic.AddInt64(&failed, 1)
+			fmt.Printf(`status=FAILED data_format=%q msg="unable to load" file=%q name=%q rows_inserted=%s error=%q`+"\n",
+				strings.ToUpper(f.dataFormat), file, fl.table, shared.FormatNumber(fl.rowsInserted), err.Error())
+			return err
+		}
+		fmt.Printf("status=SUCCESS msg=\"stopped following\" rows_inserted=%s file=%s\n", shared.FormatNumber(fl.rowsInserted), file)
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		strings.ToUpper(f.dataFormat), len(f.filesList), len(f.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+// follower loads the lines of a single file.
+type follower struct {
+	*FollowLoader
+	file  string
+	table string
+
+	// Whether the table was created, which happens with the first batch.
+	created bool
+	dialect csvutils.Dialect
+	// CSV header line, which is sent ahead of every batch.
+	header []byte
+	// Columns of JSONL records; keys that show up only later aren't loaded, which is warned
+	// about once.
+	cols       []string
+	warnedKeys bool
+
+	batch bytes.Buffer
+	rows  int
+	// When the oldest line of the batch was read.
+	since time.Time
+	// A quoted CSV field is still open at the end of the batch, so the record goes on.
+	inQuote bool
+	// Length of the batch up to the end of its last complete record.
+	complete int
+
+	rowsInserted int64
+}
+
+func (fl *follower) run(ctx context.Context) error {
+	t, err := reader.OpenTail(fl.file)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		// ctx is checked for every line, so a stop isn't held up by the catch-up of a long file.
+		caughtUp := false
+		for !caughtUp && fl.rows < fl.opts.BatchRows && ctx.Err() == nil {
+			line, first, err := t.Next()
+			if err != nil {
+				return err
+			}
+			if caughtUp = line == nil; !caughtUp {
+				if err := fl.add(ctx, line, first); err != nil {
+					return err
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return fl.stop(ctx)
+		}
+		if !fl.inQuote && (fl.rows >= fl.opts.BatchRows || fl.rows > 0 && time.Since(fl.since) >= fl.opts.FlushInterval) {
+			if err := fl.flush(ctx); err != nil {
+				if ctx.Err() != nil {
+					// The batch stays, and is loaded by stop.
+					return fl.stop(ctx)
+				}
+				return err
+			}
+		}
+		if !caughtUp {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return fl.stop(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop loads the records read so far, which ctx can't stop anymore. A record whose quoted
+// field is still open is left out, since it may never be completed.
+func (fl *follower) stop(ctx context.Context) error {
+	fl.batch.Truncate(fl.complete)
+	return fl.flush(context.WithoutCancel(ctx))
+}
+
+// add adds a complete line to the batch. first tells it's the first line of the file, which
+// is new after a rotation.
+func (fl *follower) add(ctx context.Context, line []byte, first bool) error {
+	if fl.dataFormat == shared.JSONL {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return nil
+		}
+		fl.rows++
+		if err := fl.append(line); err != nil {
+			return err
+		}
+		fl.complete = fl.batch.Len()
+		return nil
+	}
+
+	if first && !fl.inQuote {
+		if fl.header == nil && !fl.created {
+			if err := fl.detectDialect(ctx); err != nil {
+				return err
+			}
+			if !fl.dialect.NoHeader {
+				fl.header = bytes.Clone(line)
+				return nil
+			}
+		}
+		if fl.header != nil && bytes.Equal(bytes.TrimRight(line, "\r\n"), bytes.TrimRight(fl.header, "\r\n")) {
+			// The header of the file that replaced a rotated one.
+			return nil
+		}
+	}
+	fl.inQuote = inQuotedField(line, fl.inQuote, fl.dialect)
+	if err := fl.append(line); err != nil {
+		return err
+	}
+	if !fl.inQuote {
+		fl.rows++
+		fl.complete = fl.batch.Len()
+	}
+	return nil
+}
+
+func (fl *follower) append(line []byte) error {
+	if fl.batch.Len() == 0 {
+		fl.since = time.Now()
+	}
+	_, err := fl.batch.Write(line)
+	return err
+}
+
+func (fl *follower) detectDialect(ctx context.Context) (err error) {
+	fl.dialect = fl.FollowLoader.dialect.ForFile(fl.file)
+	if fl.sniff {
+		if fl.dialect, err = csvutils.DetectDialect(ctx, fl.file, fl.FollowLoader.dialect); err != nil {
+			return err
+		}
+	}
+	return fl.dialect.Validate()
+}
+
+// flush loads the batch, creating the table with the first one.
+func (fl *follower) flush(ctx context.Context) error {
+	if fl.rows == 0 {
+		return nil
+	}
+	if !fl.created {
+		if err := fl.createTable(); err != nil {
+			return err
+		}
+		fl.created = true
+	}
+
+	var (
+		rowsInserted int64
+		err          error
+	)
+	if fl.dataFormat == shared.JSONL {
+		fl.warnUnknownKeys()
+		rowsInserted, err = csv2.LoadStream(ctx, fl.table, fl.db, func(w io.Writer) error {
+			return codes.ConvertJsonlToCsv(fl.cols, bytes.NewReader(fl.batch.Bytes()), w)
+		})
+	} else {
+		r := io.MultiReader(bytes.NewReader(fl.header), bytes.NewReader(fl.batch.Bytes()))
+		rowsInserted, err = csv2.LoadCSV(ctx, r, fl.table, fl.db, fl.dialect)
+	}
+	if err != nil {
+		return err
+	}
+	fl.rowsInserted += rowsInserted
+	fl.batch.Reset()
+	fl.rows, fl.complete = 0, 0
+	fmt.Printf("status=SUCCESS msg=\"batch loaded\" rows_inserted=%s file=%s\n", shared.FormatNumber(rowsInserted), fl.file)
+	return nil
+}
+
+// warnUnknownKeys warns the first time a JSONL record of the batch has a key that isn't a
+// column of the table, whose values aren't loaded.
+func (fl *follower) warnUnknownKeys() {
+	if fl.warnedKeys {
+		return
+	}
+	known := make(map[string]bool, len(fl.cols))
+	for _, col := range fl.cols {
+		known[col] = true
+	}
+	for line := range bytes.Lines(fl.batch.Bytes()) {
+		var unknown string
+		_ = jsonparser.ObjectEach(line, func(key, _ []byte, _ jsonparser.ValueType, _ int) error {
+			if unknown == "" && !known[string(key)] {
+				unknown = string(key)
+			}
+			return nil
+		})
+		if unknown != "" {
+			fl.warnedKeys = true
+			fmt.Printf(`msg="key isn't a column of the table, its values aren't loaded" file=%q name=%q key=%q`+"\n", fl.file, fl.table, unknown)
+			return
+		}
+	}
+}
+
+// createTable creates the table with the column types inferred from the first batch.
+func (fl *follower) createTable() error {
+	var colsTypes []string
+	if fl.dataFormat == shared.JSONL {
+		var err error
+		colsTypes, fl.cols, err = shared.FindColumnTypes(bytes.NewReader(fl.batch.Bytes()), fl.lookUpSize, fl.typeSetting)
+		if err != nil {
+			return err
+		}
+	} else {
+		r := io.MultiReader(bytes.NewReader(fl.header), bytes.NewReader(fl.batch.Bytes()))
+		types, err := csvutils.FindColumnTypesIn(r, fl.lookUpSize, &fl.typeSetting, fl.dialect)
+		if err != nil {
+			return err
+		}
+		colsTypes = csvutils.BuildColumnTypeStr(types)
+	}
+	return fl.db.EnsureTable(fl.table, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", ")))
+}
+
+// inQuotedField reports whether a quoted field is still open at the end of the line, given
+// whether one was open at its start, in which case the record goes on in the next line.
+func inQuotedField(line []byte, open bool, d csvutils.Dialect) bool {
+	escaped := false
+	for _, r := range string(line) {
+		switch {
+		case escaped:
+			escaped = false
+		case open && r == d.Escape && d.Escape != d.Quote:
+			escaped = true
+		case r == d.Quote:
+			open = !open
+		}
+	}
+	return open
+}
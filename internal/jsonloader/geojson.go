@@ -0,0 +1,86 @@
+package jsonloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+)
+
+type geoJSON struct {
+	db          *dbv2.DB
+	typeSetting string
+
+	once    sync.Once
+	postgis bool
+	err     error
+}
+
+// NewGeoJSON loads GeoJSON files through the JSONL pipeline with one row per feature, so the
+// properties go through the JSON type inference. The geometry goes into a PostGIS geometry
+// column when the postgis extension is installed, and into a JSONB column otherwise.
+func NewGeoJSON(files []string, db *dbv2.DB, concurrency, lookUp int, t string) *JsonLoader {
+	g := &geoJSON{db: db, typeSetting: t}
+	j := New(files, db, concurrency, lookUp, t, "", "", 0)
+	j.dataFormat = "GEOJSON"
+	j.open = g.open
+	j.adjustTypes = g.adjustTypes
+	return j
+}
+
+func (g *geoJSON) open(ctx context.Context, file string) (io.ReadCloser, error) {
+	srid, err := g.srid(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	r, err := reader.NewFileGzipReaderContext(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	return reader.NewGeoJSONReader(r, srid), nil
+}
+
+func (g *geoJSON) adjustTypes(ctx context.Context, file string, colsTypes, cols []string) error {
+	if g.typeSetting == shared.AllText {
+		return nil
+	}
+	srid, err := g.srid(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	for i, col := range cols {
+		if col != reader.GeometryKey {
+			continue
+		}
+		colsTypes[i] = strconv.Quote(col) + " " + dbv2.Jsonb
+		if srid != 0 {
+			// A typmod like geometry(Geometry,4326) would also reject coordinates with a Z value.
+			colsTypes[i] = fmt.Sprintf("%[1]s geometry CHECK (ST_SRID(%[1]s) = %[2]d)", strconv.Quote(col), srid)
+		}
+	}
+	return nil
+}
+
+// srid returns the SRID of the file's geometries, or 0 when postgis isn't installed and they
+// are kept as GeoJSON.
+func (g *geoJSON) srid(ctx context.Context, file string) (int, error) {
+	g.once.Do(func() {
+		g.postgis, g.err = g.db.HasExtension("postgis")
+	})
+	if g.err != nil || !g.postgis {
+		return 0, g.err
+	}
+
+	r, err := reader.NewFileGzipReaderContext(ctx, file)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return reader.GeoJSONSRID(r)
+}
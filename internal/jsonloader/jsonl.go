@@ -1,6 +1,7 @@
 package jsonloader
 
 import (
+	"bufio"
 	"context"
 	"encoding/csv"
 	"encoding/json"
@@ -15,7 +16,6 @@ import (
 	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
 	"github.com/anvesh9652/pgload/pkg/shared"
 	"github.com/anvesh9652/pgload/pkg/shared/reader"
-	"github.com/sourcegraph/conc/pool"
 
 	"github.com/anvesh9652/concurrent-line-processor/examples/codes"
 )
@@ -26,28 +26,59 @@ const (
 
 type row map[string]any
 
+// bufferedFile keeps the file closable after wrapping it in a bufio.Reader.
+type bufferedFile struct {
+	*bufio.Reader
+	io.Closer
+}
+
 type JsonLoader struct {
 	maxConcurrency int
 	lookUpSize     int
 
 	typeSetting string
+	// Path to the rows inside JSON documents, like `$.data.items[*]`.
+	jsonPath string
+	// Field whose value picks the table of each record, like `type`; one table per file when empty.
+	routeBy string
+	// Most tables a file is routed into, each of which holds a connection for its COPY.
+	maxRoutes int
+	// Connections the routes of all the files can hold at once.
+	routeSlots chan struct{}
+
+	// Name used in the status lines.
+	dataFormat string
+	// Returns the file content as JSONL.
+	open func(ctx context.Context, file string) (io.ReadCloser, error)
+	// Changes the inferred column types of the file, when set.
+	adjustTypes func(ctx context.Context, file string, colsTypes, cols []string) error
 
 	filesList []string
 
 	db *dbv2.DB
 }
 
-func New(files []string, db *dbv2.DB, concurrency, lookUp int, t string) *JsonLoader {
-	return &JsonLoader{
+func New(files []string, db *dbv2.DB, concurrency, lookUp int, t, jsonPath, routeBy string, maxRoutes int) *JsonLoader {
+	j := &JsonLoader{
 		maxConcurrency: concurrency,
 		typeSetting:    t,
+		jsonPath:       jsonPath,
+		routeBy:        routeBy,
+		maxRoutes:      maxRoutes,
+		dataFormat:     "JSONL",
 		lookUpSize:     lookUp,
 		db:             db,
 		filesList:      files,
 	}
+	j.open = j.openJSONL
+	return j
 }
 
 func (j *JsonLoader) Run(ctx context.Context) (string, error) {
+	if j.routeBy != "" {
+		return j.runRouted(ctx)
+	}
+
 	var totalRowsInserted, failed int64
 	start := time.Now()
 
@@ -61,33 +92,23 @@ func (j *JsonLoader) Run(ctx context.Context) (string, error) {
 				_ = j.db.DeleteTable(name)
 			}
 		}()
-		colsTypes, cols, err := j.findTypesAndGetCols(file)
+		colsTypes, cols, err := j.findTypesAndGetCols(ctx, file)
 		if err != nil {
-			printError(file, name, err)
+			j.printError(file, name, err)
 			return err
 		}
 
 		// Ensure the table exists or create it if necessary.
 		if err = j.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
-			printError(file, name, err)
+			j.printError(file, name, err)
 			return err
 		}
 
-		pr, pw := io.Pipe()
-
-		p := pool.New().WithErrors().WithFirstError()
-		p.Go(func() error {
-			defer pw.Close()
-			return convertJsonlToCSV2(pw, file, cols)
+		rowsInserted, err := csv2.LoadStream(ctx, name, j.db, func(w io.Writer) error {
+			return j.convertJsonlToCSV2(ctx, w, file, cols)
 		})
-
-		rowsInserted, err := csv2.LoadCSV(ctx, pr, name, j.db)
 		if err != nil {
-			printError(file, name, err)
-			return err
-		}
-		if err = p.Wait(); err != nil {
-			printError(file, name, err)
+			j.printError(file, name, err)
 			return err
 		}
 		atomic.AddInt64(&totalRowsInserted, rowsInserted)
@@ -97,7 +118,7 @@ func (j *JsonLoader) Run(ctx context.Context) (string, error) {
 	})
 
 	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
-		"JSONL", len(j.filesList), len(j.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+		j.dataFormat, len(j.filesList), len(j.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
 	return msg, err
 }
 
@@ -156,8 +177,8 @@ func convertJsonlToCSV(w io.Writer, file string, cols []string) (err error) {
 }
 
 // 4-10sec faster than convertJsonlToCSV
-func convertJsonlToCSV2(w io.Writer, file string, cols []string) (err error) {
-	r, err := reader.NewFileGzipReader(file)
+func (j *JsonLoader) convertJsonlToCSV2(ctx context.Context, w io.Writer, file string, cols []string) (err error) {
+	r, err := j.open(ctx, file)
 	if err != nil {
 		return err
 	}
@@ -166,8 +187,27 @@ func convertJsonlToCSV2(w io.Writer, file string, cols []string) (err error) {
 }
 
 
-func (j *JsonLoader) findTypesAndGetCols(file string) ([]string, []string, error) {
-	r, err := reader.NewFileGzipReader(file)
+// openJSONL returns the file content as JSONL. JSON documents, like top-level arrays and
+// pretty-printed objects, or any file when a JSON path is given, are converted on the fly.
+func (j *JsonLoader) openJSONL(ctx context.Context, file string) (io.ReadCloser, error) {
+	r, err := reader.NewFileGzipReaderContext(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	bf := &bufferedFile{Reader: bufio.NewReaderSize(r, 64*1024), Closer: r}
+	if j.jsonPath == "" && reader.IsJSONLines(bf.Reader) {
+		return bf, nil
+	}
+	dr, err := reader.NewJSONDocumentReader(bf, j.jsonPath)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return dr, nil
+}
+
+func (j *JsonLoader) findTypesAndGetCols(ctx context.Context, file string) ([]string, []string, error) {
+	r, err := j.open(ctx, file)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -175,7 +215,11 @@ func (j *JsonLoader) findTypesAndGetCols(file string) ([]string, []string, error
 
 	// Even though the type setting is text, we should read some rows to find all columns that exist.
 	// In JSONL, a row might have fewer keys, while others might have more keys. So we need all of those keys.
-	return shared.FindColumnTypes(r, j.lookUpSize, j.typeSetting)
+	colsTypes, cols, err := shared.FindColumnTypes(r, j.lookUpSize, j.typeSetting)
+	if err != nil || j.adjustTypes == nil {
+		return colsTypes, cols, err
+	}
+	return colsTypes, cols, j.adjustTypes(ctx, file, colsTypes, cols)
 }
 
 
@@ -199,6 +243,6 @@ func toString(val any) string {
 	}
 }
 
-func printError(f, name string, err error) {
-	fmt.Printf(`status=FAILED data_format="JSONL" msg="unable to load" file=%q name=%q error=%q`+"\n", f, name, err.Error())
+func (j *JsonLoader) printError(f, name string, err error) {
+	fmt.Printf(`status=FAILED data_format=%q msg="unable to load" file=%q name=%q error=%q`+"\n", j.dataFormat, f, name, err.Error())
 }
@@ -0,0 +1,24 @@
+package jsonloader
+
+import (
+	"context"
+	"io"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+)
+
+// NewLogfmt loads logfmt files through the JSONL pipeline, so the columns are the union of
+// the keys found in the looked up lines.
+func NewLogfmt(files []string, db *dbv2.DB, concurrency, lookUp int, t string) *JsonLoader {
+	j := New(files, db, concurrency, lookUp, t, "", "", 0)
+	j.dataFormat = "LOGFMT"
+	j.open = func(ctx context.Context, file string) (io.ReadCloser, error) {
+		r, err := reader.NewFileGzipReaderContext(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		return reader.NewLogfmtReader(r), nil
+	}
+	return j
+}
@@ -0,0 +1,283 @@
+package jsonloader
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/buger/jsonparser"
+
+	"github.com/anvesh9652/concurrent-line-processor/examples/codes"
+)
+
+// Route value of the records without the route-by field, or with a null in it.
+const nullRoute = "null"
+
+// A table that gets the records with one value of the route-by field.
+type route struct {
+	value string
+	name  string
+
+	// Records kept until there are enough of them to infer the columns from.
+	pending [][]byte
+	// Records that came after pending while no connection was free for the route's COPY,
+	// loaded once the other routes of the file are done.
+	staged *os.File
+
+	w    *io.PipeWriter
+	bw   *bufio.Writer
+	done chan struct{}
+	// The route holds one of routeSlots.
+	slot bool
+	rows int64
+	err  error
+}
+
+// runRouted loads every file into one table per value of the route-by field, like
+// events_click and events_purchase for `--route-by type`.
+func (j *JsonLoader) runRouted(ctx context.Context) (string, error) {
+	var totalRowsInserted, total, failed int64
+	start := time.Now()
+
+	// Every route holds a connection until its file is read, so the routes of all the files
+	// share half of the connections; the rest is left to the other loads.
+	j.routeSlots = make(chan struct{}, max(j.db.MaxOpenConns()/2, 1))
+	err := shared.RunInParallel(j.maxConcurrency, j.filesList, func(file string) error {
+		routes, err := j.loadRouted(ctx, file)
+		if err != nil {
+			// None of the tables of the file are complete when it fails.
+			if len(routes) == 0 {
+				routes = []*route{{name: shared.GetTableName(file)}}
+			}
+			for _, rt := range routes {
+				_ = j.db.DeleteTable(rt.name)
+				j.printError(file, rt.name, err)
+			}
+			atomic.AddInt64(&total, int64(len(routes)))
+			atomic.AddInt64(&failed, int64(len(routes)))
+			return err
+		}
+
+		for _, rt := range routes {
+			atomic.AddInt64(&totalRowsInserted, rt.rows)
+			fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s route=%q table=%s\n",
+				shared.FormatNumber(rt.rows), shared.GetFileSize(file), file, rt.value, rt.name)
+		}
+		atomic.AddInt64(&total, int64(len(routes)))
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		j.dataFormat, total, total-failed, failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+// loadRouted reads the file once, sending every record to the COPY of its route. A route
+// starts once it has look up size records, or at the end of the file, so values that
+// show up late in the file get their own table too. Routes that find no free connection
+// are staged on disk and loaded one at a time after the others.
+func (j *JsonLoader) loadRouted(ctx context.Context, file string) (routes []*route, err error) {
+	r, err := j.open(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	defer func() {
+		// Ends the COPYs, rolling them back when loading failed.
+		for _, rt := range routes {
+			if ferr := rt.finish(err, j.routeSlots); err == nil {
+				err = ferr
+			}
+		}
+	}()
+
+	path := strings.Split(j.routeBy, ".")
+	// Values that give the same table name, like Click and click, share the route.
+	byName := map[string]*route{}
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, rerr := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			value := routeValue(line, path)
+			name := shared.GetRouteTableName(file, value)
+			rt, ok := byName[name]
+			if !ok {
+				if len(routes) == j.maxRoutes {
+					return routes, fmt.Errorf("field %q has more than %d values, each loaded into its own table; raise --max-routes or route by a field with fewer values", j.routeBy, j.maxRoutes)
+				}
+				rt = &route{value: value, name: name}
+				byName[name] = rt
+				routes = append(routes, rt)
+			}
+			if err = j.routeRecord(ctx, rt, line); err != nil {
+				return routes, err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return routes, rerr
+		}
+	}
+
+	// The started routes are done first, so their connections are free for the others.
+	var waiting []*route
+	for _, rt := range routes {
+		if rt.w == nil {
+			waiting = append(waiting, rt)
+			continue
+		}
+		if err = rt.finish(nil, j.routeSlots); err != nil {
+			return routes, err
+		}
+	}
+	for _, rt := range waiting {
+		if err = j.loadStaged(ctx, rt); err != nil {
+			return routes, err
+		}
+	}
+	return routes, nil
+}
+
+// loadStaged loads a route that didn't start while the file was read, once a connection is
+// free for it.
+func (j *JsonLoader) loadStaged(ctx context.Context, rt *route) error {
+	select {
+	case j.routeSlots <- struct{}{}:
+		rt.slot = true
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	staged := rt.staged
+	if err := j.startRoute(ctx, rt); err != nil {
+		return err
+	}
+	if staged != nil {
+		if _, err := staged.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.Copy(rt.bw, staged); err != nil {
+			return err
+		}
+	}
+	return rt.finish(nil, j.routeSlots)
+}
+
+// routeValue returns the value of the field at path as text, so 1 and "1" share a table.
+func routeValue(line []byte, path []string) string {
+	val, typ, _, err := jsonparser.Get(line, path...)
+	if err != nil || typ == jsonparser.Null {
+		return nullRoute
+	}
+	if typ == jsonparser.String {
+		if s, err := jsonparser.ParseString(val); err == nil {
+			return s
+		}
+	}
+	return string(val)
+}
+
+func (j *JsonLoader) routeRecord(ctx context.Context, rt *route, line []byte) error {
+	if rt.bw != nil {
+		return rt.write(line)
+	}
+	rt.pending = append(rt.pending, line)
+	if len(rt.pending) < min(j.lookUpSize, shared.MaxRowsReadLimit) {
+		return nil
+	}
+
+	select {
+	case j.routeSlots <- struct{}{}:
+		rt.slot = true
+		return j.startRoute(ctx, rt)
+	default:
+		// Waiting for a connection here could wait on the routes of this file.
+		f, err := os.CreateTemp("", "pgload-route-")
+		if err != nil {
+			return err
+		}
+		rt.staged, rt.bw = f, bufio.NewWriterSize(f, 64*1024)
+		return nil
+	}
+}
+
+// startRoute creates the table of the route from its pending records and starts its COPY.
+func (j *JsonLoader) startRoute(ctx context.Context, rt *route) error {
+	if rt.staged != nil {
+		if err := rt.bw.Flush(); err != nil {
+			return err
+		}
+	}
+	sample := bytes.Join(rt.pending, []byte{'\n'})
+	colsTypes, cols, err := shared.FindColumnTypes(bytes.NewReader(sample), j.lookUpSize, j.typeSetting)
+	if err != nil {
+		return err
+	}
+	// Ensure the table exists or create it if necessary.
+	if err = j.db.EnsureTable(rt.name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	rt.w, rt.bw, rt.done = pw, bufio.NewWriterSize(pw, 64*1024), make(chan struct{})
+	go func() {
+		defer close(rt.done)
+		rt.rows, rt.err = csv2.LoadStream(ctx, rt.name, j.db, func(w io.Writer) error {
+			return codes.ConvertJsonlToCsv(cols, pr, w)
+		})
+		// Unblock the records still being routed here if COPY stopped early.
+		pr.CloseWithError(cmp.Or(rt.err, io.ErrClosedPipe))
+	}()
+
+	pending := rt.pending
+	rt.pending = nil
+	for _, line := range pending {
+		if err = rt.write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rt *route) write(line []byte) error {
+	if _, err := rt.bw.Write(line); err != nil {
+		return err
+	}
+	return rt.bw.WriteByte('\n')
+}
+
+// finish ends the input of the route's COPY, making it fail with err when set, waits for it
+// and gives its connection back to slots. It can be called more than once.
+func (rt *route) finish(err error, slots chan struct{}) error {
+	if rt.staged != nil {
+		rt.staged.Close()
+		os.Remove(rt.staged.Name())
+		rt.staged = nil
+	}
+	if rt.slot {
+		defer func() {
+			rt.slot = false
+			<-slots
+		}()
+	}
+	if rt.w == nil {
+		return nil
+	}
+	if err == nil {
+		err = rt.bw.Flush()
+	}
+	rt.w.CloseWithError(err)
+	<-rt.done
+	return cmp.Or(err, rt.err)
+}
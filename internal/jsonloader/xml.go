@@ -0,0 +1,29 @@
+package jsonloader
+
+import (
+	"context"
+	"io"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+)
+
+// NewXML loads XML files through the JSONL pipeline. Each element at recordPath, like
+// `/catalog/item`, becomes a row; when recordPath is empty, the children of the root element are used.
+func NewXML(files []string, db *dbv2.DB, concurrency, lookUp int, t, recordPath string) *JsonLoader {
+	j := New(files, db, concurrency, lookUp, t, "", "", 0)
+	j.dataFormat = "XML"
+	j.open = func(ctx context.Context, file string) (io.ReadCloser, error) {
+		r, err := reader.NewFileGzipReaderContext(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		xr, err := reader.NewXMLRecordReader(r, recordPath)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		return xr, nil
+	}
+	return j
+}
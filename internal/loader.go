@@ -4,16 +4,29 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	builterr "errors"
 
+	"github.com/anvesh9652/pgload/internal/arrowloader"
+	"github.com/anvesh9652/pgload/internal/avroloader"
 	csvloader "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/followloader"
 	"github.com/anvesh9652/pgload/internal/jsonloader"
+	"github.com/anvesh9652/pgload/internal/parquetloader"
+	"github.com/anvesh9652/pgload/internal/patternloader"
 	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/internal/postgresloader"
+	"github.com/anvesh9652/pgload/internal/protoloader"
+	"github.com/anvesh9652/pgload/internal/sqldumploader"
+	"github.com/anvesh9652/pgload/internal/sqliteloader"
+	"github.com/anvesh9652/pgload/internal/xlsxloader"
 	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/conc/pool"
 	"github.com/spf13/cobra"
@@ -34,6 +47,8 @@ type CommandInfo struct {
 	flagsMapB map[string]bool
 
 	db *dbv2.DB
+	// Lines of the archive members that can't be loaded, for the final stats.
+	skippedMembers []string
 }
 
 func NewCommandInfo(ctx context.Context, cmd *cobra.Command, args []string) (*CommandInfo, error) {
@@ -53,7 +68,7 @@ func NewCommandInfo(ctx context.Context, cmd *cobra.Command, args []string) (*Co
 		case "string":
 			c.flagsMapS[f.Name] = f.Value.String()
 		case "int":
-			val, err := flags.GetInt(LookUp)
+			val, err := flags.GetInt(f.Name)
 			if err != nil {
 				log.Printf("Error while retrieving %s flag value\n", f.Name)
 				visitErrors = append(visitErrors, err)
@@ -91,99 +106,463 @@ func (c *CommandInfo) RunLoader(ctx context.Context) error {
 	if err := c.db.EnsureSchema(); err != nil {
 		return err
 	}
+	if c.flagsMapS[SourceDSN] != "" || slices.ContainsFunc(c.args, postgresloader.IsSourceURL) {
+		return c.transferTables(ctx)
+	}
 
 	allFiles, err := c.collectFiles()
 	if err != nil {
 		return err
 	}
+	if err = c.checkStdin(allFiles); err != nil {
+		return err
+	}
+
+	var tars []string
+	allFiles = slices.DeleteFunc(allFiles, func(file string) bool {
+		if isTarArchive(file) {
+			tars = append(tars, file)
+			return true
+		}
+		return false
+	})
+	files := c.categorizeFiles(allFiles)
+	if err = checkStreamedInputs(files); err != nil {
+		return err
+	}
+	if err = c.checkFollow(files); err != nil {
+		return err
+	}
+	if c.flagsMapB[Follow] && len(tars) > 0 {
+		return fmt.Errorf("%s can't be followed, only local files that aren't compressed can", tars[0])
+	}
+	if len(tars) == 0 {
+		return c.RunFormatSpecificLoaders(ctx, files)
+	}
 
-	csvFiles, jsonFiles := c.categorizeFiles(allFiles)
-	return c.RunFormatSpecificLoaders(ctx, csvFiles, jsonFiles)
+	var errs []error
+	if len(allFiles) > 0 {
+		errs = append(errs, c.RunFormatSpecificLoaders(ctx, files))
+	}
+	for _, archive := range tars {
+		errs = append(errs, c.streamTar(ctx, archive))
+	}
+	return builterr.Join(errs...)
 }
 
-func (c *CommandInfo) collectFiles() ([]string, error) {
-	var allFiles []string
-	for _, arg := range c.args {
-		if strings.Contains(arg, "*") {
-			result, err := filepath.Glob(arg)
+// streamTar loads the members of a tar archive in a single pass over it, one member after
+// the other, since a member can only be read while the archive is at it.
+func (c *CommandInfo) streamTar(ctx context.Context, archive string) error {
+	start := time.Now()
+	exclude := splitPatterns(c.flagsMapS[Exclude])
+	var (
+		lines         []string
+		errs          []error
+		total, loaded int
+	)
+	err := reader.StreamTar(ctx, archive, func(member string, size int64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, name, _ := shared.SplitArchivePath(member)
+		if reason := skipReason(name); reason != "" {
+			lines = append(lines, skippedMember(archive, name, reason))
+			return nil
+		}
+		kept, err := excludeFiles([]string{member}, exclude)
+		if err != nil {
+			return err
+		}
+		if len(kept) == 0 || !c.isLoadable(member) {
+			return nil
+		}
+
+		shared.RecordFileSize(member, size)
+		files := c.categorizeFiles(kept)
+		total++
+		// The per-format stats of a single member are left out; its status line has them.
+		if _, err := c.runLoaders(ctx, files); err != nil {
+			errs = append(errs, err)
+		} else {
+			loaded++
+		}
+		lines = append(lines, archiveMemberStats(c.flagsMapS[Format], files)...)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, errors.Wrapf(err, "unable to read archive %s", archive))
+	}
+
+	msg := fmt.Sprintf(`msg="archive load stats" archive=%q total=%d success=%d failed=%d took=%s`,
+		archive, total, loaded, total-loaded, time.Since(start))
+	fmt.Println(strings.Join(append([]string{msg}, lines...), "\n"))
+	return builterr.Join(errs...)
+}
+
+// transferTables copies the result of --query on the --source-dsn database, or the pg://
+// source tables given as arguments, into the target database.
+func (c *CommandInfo) transferTables(ctx context.Context) error {
+	dsn, query, table := c.flagsMapS[SourceDSN], c.flagsMapS[Query], c.flagsMapS[Table]
+	var sources []postgresloader.Source
+	if dsn != "" {
+		switch {
+		case len(c.args) > 0:
+			return fmt.Errorf("flag %q can't be used along with files or pg:// sources", SourceDSN)
+		case query == "":
+			return fmt.Errorf("flag %q needs the query to copy, given with flag %q", SourceDSN, Query)
+		case table == "":
+			return fmt.Errorf("flag %q needs a table name, given with flag %q", SourceDSN, Table)
+		}
+		sources = append(sources, postgresloader.Source{
+			DSN:   dsn,
+			Query: query,
+			Table: shared.GetObjectTableName(table),
+			Name:  postgresloader.RedactDSN(dsn),
+		})
+	} else {
+		if query != "" || table != "" {
+			return fmt.Errorf("flags %q and %q need flag %q; pg:// sources are loaded into tables of their own name", Query, Table, SourceDSN)
+		}
+		for _, arg := range c.args {
+			if !postgresloader.IsSourceURL(arg) {
+				return fmt.Errorf("pg:// sources can't be loaded along with files: %s", arg)
+			}
+			src, err := postgresloader.ParseSourceURL(arg)
 			if err != nil {
-				return nil, errors.Wrapf(err, "glob pattern matching failed: %s", arg)
+				return err
 			}
-			allFiles = append(allFiles, result...)
-			continue
+			sources = append(sources, src)
 		}
-		allFiles = append(allFiles, arg)
 	}
-	return allFiles, nil
+
+	typeSetting := c.flagsMapS[Type]
+	if typeSetting != shared.Dynamic && typeSetting != shared.AllText {
+		return fmt.Errorf("unknown value for type %q", typeSetting)
+	}
+	msg, err := postgresloader.New(sources, c.db, concurrentRuns, typeSetting).Run(ctx)
+	fmt.Println(msg)
+	return err
 }
 
-func (c *CommandInfo) categorizeFiles(allFiles []string) ([]string, []string) {
-	var csvFiles, jsonFiles []string
-	for _, file := range allFiles {
-		if shared.IsCSVFile(file) {
-			csvFiles = append(csvFiles, file)
-			continue
+// checkStdin makes sure stdin input, "-", comes alone and with a table name given with
+// --table, since there's no file name to pick the format and the table from.
+func (c *CommandInfo) checkStdin(files []string) error {
+	format, table := c.flagsMapS[Format], c.flagsMapS[Table]
+	if !slices.Contains(files, shared.Stdin) {
+		if table != "" {
+			return fmt.Errorf("flag %q only names the table of stdin input (-) or of the --%s result", Table, Query)
 		}
-		if shared.IsJSONFile(file) {
-			jsonFiles = append(jsonFiles, file)
+		return nil
+	}
+
+	switch {
+	case len(files) > 1:
+		return errors.New("stdin input (-) can't be loaded along with other files")
+	case table == "":
+		return fmt.Errorf("stdin input (-) needs a table name, given with flag %q", Table)
+	}
+	if format == shared.Both {
+		return fmt.Errorf("stdin input (-) needs a single format given with flag %q", "-f")
+	}
+	shared.RecordTableName(shared.Stdin, table)
+	return nil
+}
+
+// checkStreamedInputs makes sure stdin and remote inputs aren't in formats that are read with
+// random access.
+func checkStreamedInputs(files map[string][]string) error {
+	for _, format := range []string{shared.Parquet, shared.Arrow, shared.XLSX, shared.SQLite} {
+		for _, file := range files[format] {
+			if file == shared.Stdin || shared.IsRemoteFile(file) {
+				return fmt.Errorf("%s data can't be streamed from %s, it needs random access", strings.ToUpper(format), file)
+			}
 		}
 	}
-	return csvFiles, jsonFiles
+	return nil
 }
 
-func (c *CommandInfo) RunFormatSpecificLoaders(ctx context.Context, cf, jf []string) error {
+// checkFollow makes sure --follow is only given for local CSV and JSONL files that aren't
+// compressed, which can be read on as they grow.
+func (c *CommandInfo) checkFollow(files map[string][]string) error {
+	if !c.flagsMapB[Follow] {
+		return nil
+	}
 	format := c.flagsMapS[Format]
-	if err := validateFileFormats(format, cf, jf); err != nil {
+	if format != shared.CSV && format != shared.JSONL && format != shared.Both {
+		return fmt.Errorf("flag %q only works for CSV and JSONL files", Follow)
+	}
+	for _, flag := range []string{JSONPath, RouteBy} {
+		if c.flagsMapS[flag] != "" {
+			return fmt.Errorf("flag %q can't be used with flag %q", flag, Follow)
+		}
+	}
+	if _, err := c.followOptions(); err != nil {
 		return err
 	}
+	for _, f := range formatsToLoad(format) {
+		for _, file := range files[f] {
+			if file == shared.Stdin || shared.IsRemoteFile(file) || shared.IsCompressedFile(file) {
+				return fmt.Errorf("%s can't be followed, only local files that aren't compressed can", file)
+			}
+			if _, _, ok := shared.SplitArchivePath(file); ok {
+				return fmt.Errorf("%s can't be followed, only local files that aren't compressed can", file)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *CommandInfo) followOptions() (followloader.Options, error) {
+	opts := followloader.Options{BatchRows: c.flagsMapI[BatchRows]}
+	interval, err := time.ParseDuration(c.flagsMapS[FlushInterval])
+	if err != nil || interval <= 0 {
+		return opts, fmt.Errorf("invalid duration %q for flag %q", c.flagsMapS[FlushInterval], FlushInterval)
+	}
+	if opts.BatchRows <= 0 {
+		return opts, fmt.Errorf("flag %q must be positive", BatchRows)
+	}
+	opts.FlushInterval = interval
+	return opts, nil
+}
+
+// categorizeFiles groups the files by the data format they hold.
+func (c *CommandInfo) categorizeFiles(allFiles []string) map[string][]string {
+	files := make(map[string][]string)
+	for _, file := range allFiles {
+		// The path of URLs and archive members, without the host, query or archive.
+		name := shared.InputName(file)
+		switch {
+		case file == shared.Stdin:
+			files[c.flagsMapS[Format]] = append(files[c.flagsMapS[Format]], file)
+		case shared.IsCSVFile(name):
+			files[shared.CSV] = append(files[shared.CSV], file)
+		case shared.IsJSONFile(name):
+			files[shared.JSONL] = append(files[shared.JSONL], file)
+		case shared.IsGeoJSONFile(name):
+			files[shared.GeoJSON] = append(files[shared.GeoJSON], file)
+		case shared.IsParquetFile(name):
+			files[shared.Parquet] = append(files[shared.Parquet], file)
+		case shared.IsAvroFile(name):
+			files[shared.Avro] = append(files[shared.Avro], file)
+		case shared.IsArrowFile(name):
+			files[shared.Arrow] = append(files[shared.Arrow], file)
+		case shared.IsExcelFile(name):
+			files[shared.XLSX] = append(files[shared.XLSX], file)
+		case shared.IsXMLFile(name):
+			files[shared.XML] = append(files[shared.XML], file)
+		case shared.IsSQLiteFile(name):
+			files[shared.SQLite] = append(files[shared.SQLite], file)
+		case shared.IsSQLFile(name):
+			files[shared.SQL] = append(files[shared.SQL], file)
+		case shared.IsProtobufFile(name):
+			files[shared.Protobuf] = append(files[shared.Protobuf], file)
+		case shared.IsLogFile(name):
+			files[c.logFormat()] = append(files[c.logFormat()], file)
+		case c.flagsMapS[Format] == shared.Pattern, c.flagsMapS[Format] == shared.Protobuf:
+			// Log files like syslog's "messages" and protobuf dumps often have no extension at all.
+			files[c.flagsMapS[Format]] = append(files[c.flagsMapS[Format]], file)
+		case shared.IsURL(file):
+			// URLs like https://host/export?format=csv don't have an extension either.
+			files[c.flagsMapS[Format]] = append(files[c.flagsMapS[Format]], file)
+		}
+	}
+	return files
+}
+
+func (c *CommandInfo) RunFormatSpecificLoaders(ctx context.Context, files map[string][]string) error {
+	msgs, err := c.runLoaders(ctx, files)
+	if msgs == nil && err != nil {
+		return err
+	}
+	msgs = append(msgs, archiveMemberStats(c.flagsMapS[Format], files)...)
+	msgs = append(msgs, c.skippedMembers...)
+	fmt.Println(strings.Join(msgs, "\n"))
+	return err
+}
+
+// runLoaders loads the files with the loaders of their formats, and returns the final load
+// stats of every loader.
+func (c *CommandInfo) runLoaders(ctx context.Context, files map[string][]string) ([]string, error) {
+	format := c.flagsMapS[Format]
+	if err := validateFileFormats(format, files); err != nil {
+		return nil, err
+	}
 
 	lookUp, typeSetting := c.flagsMapI[LookUp], c.flagsMapS[Type]
 	if typeSetting != shared.Dynamic && typeSetting != shared.AllText {
-		return fmt.Errorf("unknown value for type %q", typeSetting)
+		return nil, fmt.Errorf("unknown value for type %q", typeSetting)
+	}
+	dialect, err := c.csvDialect()
+	if err != nil {
+		return nil, err
+	}
+	if c.flagsMapS[RouteBy] != "" && c.flagsMapI[MaxRoutes] <= 0 {
+		return nil, fmt.Errorf("flag %q must be positive", MaxRoutes)
+	}
+
+	loaders := map[string]func(files []string) (string, error){
+		shared.CSV: func(files []string) (string, error) {
+			return csvloader.NewCSVLoader(files, c.db, lookUp, typeSetting, concurrentRuns, dialect, c.flagsMapB[Sniff]).Run(ctx)
+		},
+		shared.JSONL: func(files []string) (string, error) {
+			return jsonloader.New(files, c.db, concurrentRuns, lookUp, typeSetting, c.flagsMapS[JSONPath], c.flagsMapS[RouteBy], c.flagsMapI[MaxRoutes]).Run(ctx)
+		},
+		shared.GeoJSON: func(files []string) (string, error) {
+			return jsonloader.NewGeoJSON(files, c.db, concurrentRuns, lookUp, typeSetting).Run(ctx)
+		},
+		shared.Parquet: func(files []string) (string, error) {
+			return parquetloader.New(files, c.db, concurrentRuns, typeSetting).Run(ctx)
+		},
+		shared.Avro: func(files []string) (string, error) {
+			return avroloader.New(files, c.db, concurrentRuns, typeSetting).Run(ctx)
+		},
+		shared.Arrow: func(files []string) (string, error) {
+			return arrowloader.New(files, c.db, concurrentRuns, typeSetting).Run(ctx)
+		},
+		shared.XLSX: func(files []string) (string, error) {
+			return xlsxloader.New(files, c.db, concurrentRuns, lookUp, typeSetting, c.xlsxOptions()).Run(ctx)
+		},
+		shared.XML: func(files []string) (string, error) {
+			return jsonloader.NewXML(files, c.db, concurrentRuns, lookUp, typeSetting, c.flagsMapS[RecordPath]).Run(ctx)
+		},
+		shared.Logfmt: func(files []string) (string, error) {
+			return jsonloader.NewLogfmt(files, c.db, concurrentRuns, lookUp, typeSetting).Run(ctx)
+		},
+		shared.Pattern: func(files []string) (string, error) {
+			return patternloader.New(files, c.db, concurrentRuns, lookUp, typeSetting, c.flagsMapS[LogPattern], c.flagsMapS[RejectsDir]).Run(ctx)
+		},
+		shared.Protobuf: func(files []string) (string, error) {
+			return protoloader.New(files, c.db, concurrentRuns, typeSetting, c.flagsMapS[DescriptorSet], c.flagsMapS[Message]).Run(ctx)
+		},
+		shared.SQL: func(files []string) (string, error) {
+			return sqldumploader.New(files, c.db, concurrentRuns, typeSetting).Run(ctx)
+		},
+		shared.SQLite: func(files []string) (string, error) {
+			return sqliteloader.New(files, c.db, concurrentRuns, lookUp, typeSetting, splitList(c.flagsMapS[Tables])).Run(ctx)
+		},
+	}
+
+	if c.flagsMapB[Follow] {
+		opts, err := c.followOptions()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range []string{shared.CSV, shared.JSONL} {
+			loaders[f] = func(files []string) (string, error) {
+				return followloader.New(f, files, c.db, lookUp, typeSetting, dialect, c.flagsMapB[Sniff], opts).Run(ctx)
+			}
+		}
 	}
 
 	mu := new(sync.Mutex)
 	msgs := []string{}
 	pool := pool.New().WithErrors()
-	if len(cf) > 0 && (format == shared.CSV || format == shared.Both) {
+	for _, f := range formatsToLoad(format) {
+		if len(files[f]) == 0 {
+			continue
+		}
 		pool.Go(func() error {
-			msg, err := csvloader.NewCSVLoader(cf, c.db, lookUp, typeSetting, concurrentRuns).Run(ctx)
+			msg, err := loaders[f](files[f])
 			mu.Lock()
 			msgs = append(msgs, msg)
 			mu.Unlock()
 			return err
 		})
 	}
-	if len(jf) > 0 && (format == shared.JSONL || format == shared.Both) {
-		pool.Go(func() error {
-			msg, err := jsonloader.New(jf, c.db, concurrentRuns, lookUp, typeSetting).Run(ctx)
-			mu.Lock()
-			msgs = append(msgs, msg)
-			mu.Unlock()
-			return err
-		})
+
+	err = pool.Wait()
+	return msgs, err
+}
+
+// archiveMemberStats returns a line for every archive member that was loaded, to go with
+// the final load stats.
+func archiveMemberStats(format string, files map[string][]string) []string {
+	var lines []string
+	for _, f := range formatsToLoad(format) {
+		for _, file := range files[f] {
+			archive, member, ok := shared.SplitArchivePath(file)
+			if !ok {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf(`msg="archive member" archive=%q member=%q data_format=%q file_size=%s`,
+				archive, member, strings.ToUpper(f), shared.GetFileSize(file)))
+		}
 	}
+	return lines
+}
 
-	err := pool.Wait()
-	fmt.Println(strings.Join(msgs, "\n"))
-	return err
+func (c *CommandInfo) xlsxOptions() xlsxloader.Options {
+	opts := xlsxloader.Options{
+		HeaderOffset: c.flagsMapI[HeaderOffset],
+		Range:        c.flagsMapS[CellRange],
+	}
+	opts.Sheets = splitList(c.flagsMapS[Sheets])
+	return opts
+}
+
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(val string) []string {
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (c *CommandInfo) csvDialect() (csvutils.Dialect, error) {
+	var d csvutils.Dialect
+	for flag, char := range map[string]*rune{Delimiter: &d.Delimiter, Quote: &d.Quote, Escape: &d.Escape} {
+		r, err := csvutils.ParseDialectChar(c.flagsMapS[flag])
+		if err != nil {
+			return d, errors.Wrapf(err, "invalid value for flag %q", flag)
+		}
+		*char = r
+	}
+	switch {
+	case c.flagsMapB[Header] && c.flagsMapB[NoHeader]:
+		return d, fmt.Errorf("flags %q and %q can't be used together", Header, NoHeader)
+	case c.flagsMapB[Header]:
+		d.HeaderSet = true
+	case c.flagsMapB[NoHeader]:
+		d.NoHeader, d.HeaderSet = true, true
+	}
+	return d, nil
+}
+
+// logFormat is the format log files are loaded as, logfmt unless patterns are asked for.
+func (c *CommandInfo) logFormat() string {
+	if c.flagsMapS[Format] == shared.Pattern {
+		return shared.Pattern
+	}
+	return shared.Logfmt
+}
+
+// formatsToLoad expands the format flag into the data formats it selects.
+func formatsToLoad(format string) []string {
+	if format == shared.Both {
+		return []string{shared.CSV, shared.JSONL}
+	}
+	return []string{format}
 }
 
 func isAcceptableFormat(format string) bool {
-	return format != shared.CSV && format != shared.JSONL && format != shared.Both
+	switch format {
+	case shared.CSV, shared.JSONL, shared.Parquet, shared.Avro, shared.Arrow, shared.XLSX, shared.XML, shared.SQLite, shared.Logfmt, shared.Pattern, shared.SQL, shared.Protobuf, shared.GeoJSON, shared.Both:
+		return true
+	}
+	return false
 }
 
-func validateFileFormats(format string, cf, jf []string) error {
-	if isAcceptableFormat(format) {
+func validateFileFormats(format string, files map[string][]string) error {
+	if !isAcceptableFormat(format) {
 		return fmt.Errorf("unknown file format %q is given for flag %q", format, "-f")
 	}
-	if format == shared.CSV && len(cf) == 0 {
-		return errors.New("at least provide one CSV file")
-	}
-	if format == shared.JSONL && len(jf) == 0 {
-		return errors.New("at least provide one JSONL file")
+	if format != shared.Both && len(files[format]) == 0 {
+		return fmt.Errorf("at least provide one %s file", strings.ToUpper(format))
 	}
-	if len(cf)+len(jf) == 0 {
+	if len(files[shared.CSV])+len(files[shared.JSONL]) == 0 && format == shared.Both {
 		return errors.New("at least provide one file")
 	}
 	return nil
@@ -0,0 +1,119 @@
+package parquetloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/arrowutils"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// Number of rows decoded per record batch.
+const batchSize = 64 * 1024
+
+type ParquetLoader struct {
+	maxConcurrency int
+
+	typeSetting string
+
+	filesList []string
+
+	db *dbv2.DB
+}
+
+func New(files []string, db *dbv2.DB, concurrency int, t string) *ParquetLoader {
+	return &ParquetLoader{
+		maxConcurrency: concurrency,
+		typeSetting:    t,
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (p *ParquetLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, failed int64
+	start := time.Now()
+
+	err := shared.RunInParallel(p.maxConcurrency, p.filesList, func(file string) error {
+		var err error
+
+		name := shared.GetTableName(file)
+		defer func() {
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = p.db.DeleteTable(name)
+			}
+		}()
+
+		rowsInserted, err := p.load(ctx, file, name)
+		if err != nil {
+			printError(file, name, err)
+			return err
+		}
+		atomic.AddInt64(&totalRowsInserted, rowsInserted)
+		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s\n",
+			shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file)
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"PARQUET", len(p.filesList), len(p.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+func (p *ParquetLoader) load(ctx context.Context, path, name string) (int64, error) {
+	pf, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return 0, err
+	}
+	defer pf.Close()
+
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: batchSize}, memory.DefaultAllocator)
+	if err != nil {
+		return 0, err
+	}
+	// The table is created from the schema stored in the file footer instead of sampled rows.
+	schema, err := fr.Schema()
+	if err != nil {
+		return 0, err
+	}
+	colsTypes, cols := arrowutils.ColumnTypes(schema, p.typeSetting)
+
+	// Ensure the table exists or create it if necessary.
+	if err = p.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return 0, err
+	}
+
+	return csv2.LoadTextStream(ctx, name, p.db, cols, func(w io.Writer) error {
+		rr, err := fr.GetRecordReader(ctx, nil, nil)
+		if err != nil {
+			return err
+		}
+		defer rr.Release()
+
+		bw := bufio.NewWriterSize(w, 64*1024)
+		for rr.Next() {
+			if err := arrowutils.WriteRecordText(bw, rr.RecordBatch()); err != nil {
+				return err
+			}
+		}
+		if err := rr.Err(); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+}
+
+func printError(f, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="PARQUET" msg="unable to load" file=%q name=%q error=%q`+"\n", f, name, err.Error())
+}
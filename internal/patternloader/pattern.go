@@ -0,0 +1,309 @@
+package patternloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+)
+
+type PatternLoader struct {
+	maxConcurrency int
+	lookUpSize     int
+
+	typeSetting string
+	// Built-in pattern name or a regex with named groups.
+	pattern string
+	// Lines that don't match are saved to <table>.rejects.log in this directory when set.
+	rejectsDir string
+
+	filesList []string
+
+	db *dbv2.DB
+}
+
+func New(files []string, db *dbv2.DB, concurrency, lookUp int, t, pattern, rejectsDir string) *PatternLoader {
+	return &PatternLoader{
+		maxConcurrency: concurrency,
+		lookUpSize:     lookUp,
+		typeSetting:    t,
+		pattern:        pattern,
+		rejectsDir:     rejectsDir,
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (l *PatternLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, totalRejected, failed int64
+	start := time.Now()
+
+	p, err := newPattern(l.pattern)
+	if err != nil {
+		return "", err
+	}
+
+	err = shared.RunInParallel(l.maxConcurrency, l.filesList, func(file string) error {
+		var err error
+
+		name := shared.GetTableName(file)
+		defer func() {
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = l.db.DeleteTable(name)
+			}
+		}()
+
+		rowsInserted, rejected, err := l.load(ctx, p, file, name)
+		if err != nil {
+			printError(file, name, err)
+			return err
+		}
+		atomic.AddInt64(&totalRowsInserted, rowsInserted)
+		atomic.AddInt64(&totalRejected, rejected)
+		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s lines_rejected=%s\n",
+			shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file, shared.FormatNumber(rejected))
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s total_lines_rejected=%s took=%s`,
+		"PATTERN", len(l.filesList), len(l.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted),
+		shared.FormatNumber(totalRejected), time.Since(start))
+	return msg, err
+}
+
+func (l *PatternLoader) load(ctx context.Context, p *pattern, file, name string) (int64, int64, error) {
+	cols, err := l.columns(ctx, p, file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	colsTypes := make([]string, len(cols))
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.name
+		colsTypes[i] = strconv.Quote(col.name) + " " + col.pgType
+	}
+	// Ensure the table exists or create it if necessary.
+	if err = l.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return 0, 0, err
+	}
+
+	var rejected int64
+	rowsInserted, err := csv2.LoadTextStream(ctx, name, l.db, names, func(w io.Writer) error {
+		var err error
+		rejected, err = l.convertLines(ctx, w, p, cols, file, name)
+		return err
+	})
+	return rowsInserted, rejected, err
+}
+
+// columns returns the columns of a built-in pattern, or types the named groups of a custom
+// pattern from the lines matched within the look up size.
+func (l *PatternLoader) columns(ctx context.Context, p *pattern, file string) ([]column, error) {
+	cols := p.cols
+	if cols == nil {
+		var err error
+		if cols, err = l.inferColumns(ctx, p, file); err != nil {
+			return nil, err
+		}
+	}
+	if l.typeSetting != shared.AllText {
+		return cols, nil
+	}
+
+	textCols := make([]column, len(cols))
+	for i, col := range cols {
+		textCols[i] = column{name: col.name, pgType: dbv2.Text}
+	}
+	return textCols, nil
+}
+
+func (l *PatternLoader) inferColumns(ctx context.Context, p *pattern, file string) ([]column, error) {
+	var names []string
+	for _, name := range p.re.SubexpNames() {
+		// Go allows a name on more than one group, the first one is used.
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	values := make([][]string, len(names))
+
+	matched := 0
+	err := eachLine(ctx, file, func(line string) error {
+		sub := p.re.FindStringSubmatchIndex(line)
+		if sub == nil {
+			return nil
+		}
+		matched++
+		for i, name := range names {
+			g := p.re.SubexpIndex(name)
+			if sub[2*g] == -1 {
+				continue
+			}
+			if val := line[sub[2*g]:sub[2*g+1]]; val != "" && val != p.nilValue {
+				values[i] = append(values[i], val)
+			}
+		}
+		if matched >= l.lookUpSize {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cols := make([]column, len(names))
+	for i, name := range names {
+		cols[i] = inferColumn(name, values[i])
+	}
+	return cols, nil
+}
+
+// convertLines writes the matching lines in the text format of COPY and returns the number of
+// lines that didn't match, or whose values couldn't be converted to the column types.
+func (l *PatternLoader) convertLines(ctx context.Context, w io.Writer, p *pattern, cols []column, file, name string) (int64, error) {
+	var matched, rejected int64
+	var rejects *rejectsFile
+	defer func() {
+		if rejects != nil {
+			rejects.Close()
+		}
+	}()
+
+	groups := p.groups(cols)
+	bw := bufio.NewWriterSize(w, 64*1024)
+	values := make([]string, len(cols))
+	err := eachLine(ctx, file, func(line string) error {
+		if writeRow(bw, p, cols, groups, line, values) {
+			matched++
+			return nil
+		}
+
+		rejected++
+		if l.rejectsDir == "" {
+			return nil
+		}
+		if rejects == nil {
+			var err error
+			if rejects, err = createRejectsFile(l.rejectsDir, name); err != nil {
+				return err
+			}
+		}
+		return rejects.write(line)
+	})
+	if err != nil {
+		return rejected, err
+	}
+	if matched == 0 && rejected > 0 {
+		return rejected, fmt.Errorf("none of the %d lines matched the pattern", rejected)
+	}
+	return rejected, bw.Flush()
+}
+
+// writeRow writes the line as a row when it matches and all of its values convert.
+func writeRow(bw *bufio.Writer, p *pattern, cols []column, groups []int, line string, values []string) bool {
+	sub := p.re.FindStringSubmatchIndex(line)
+	if sub == nil {
+		return false
+	}
+	for i, col := range cols {
+		g := groups[i]
+		if g == -1 || sub[2*g] == -1 {
+			values[i] = csvutils.CopyTextNull
+			continue
+		}
+		val := line[sub[2*g]:sub[2*g+1]]
+		if val == p.nilValue || (val == "" && col.pgType != dbv2.Text) {
+			values[i] = csvutils.CopyTextNull
+			continue
+		}
+		if col.convert != nil {
+			var err error
+			if val, err = col.convert(val); err != nil {
+				return false
+			}
+		}
+		values[i] = csvutils.CopyTextEscaper.Replace(val)
+	}
+
+	bw.WriteString(strings.Join(values, "\t"))
+	bw.WriteByte('\n')
+	return true
+}
+
+// eachLine calls fn with every non-empty line of the file until fn returns an error.
+// io.EOF from fn stops early without an error.
+func eachLine(ctx context.Context, file string, fn func(line string) error) error {
+	r, err := reader.NewFileGzipReaderContext(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, rerr := br.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			if err := fn(line); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+type rejectsFile struct {
+	*bufio.Writer
+	f *os.File
+}
+
+func createRejectsFile(dir, name string) (*rejectsFile, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, name+".rejects.log"))
+	if err != nil {
+		return nil, err
+	}
+	return &rejectsFile{Writer: bufio.NewWriter(f), f: f}, nil
+}
+
+func (r *rejectsFile) write(line string) error {
+	_, err := r.WriteString(line + "\n")
+	return err
+}
+
+func (r *rejectsFile) Close() error {
+	if err := r.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+func printError(f, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="PATTERN" msg="unable to load" file=%q name=%q error=%q`+"\n", f, name, err.Error())
+}
@@ -0,0 +1,228 @@
+package patternloader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+)
+
+// Names of the built-in patterns.
+const (
+	Combined = "combined"
+	RFC3164  = "rfc3164"
+	RFC5424  = "rfc5424"
+)
+
+const (
+	accessLogLayout = "02/Jan/2006:15:04:05 -0700"
+	rfc3164Layout   = "Jan _2 15:04:05"
+)
+
+type column struct {
+	name   string
+	pgType string
+	// Turns the matched text into a value COPY accepts for pgType. Nil keeps the text as is.
+	convert func(string) (string, error)
+}
+
+type pattern struct {
+	re *regexp.Regexp
+	// Matched text that stands for a missing value, like "-" in access logs.
+	nilValue string
+	// Set for the built-in patterns; the columns of custom patterns are typed from a sample.
+	cols []column
+}
+
+var builtinPatterns = map[string]func() *pattern{
+	// Apache/Nginx combined log format. The referer and user agent are optional,
+	// so the common log format matches too.
+	Combined: func() *pattern {
+		return &pattern{
+			re: regexp.MustCompile(`^(?P<remote_addr>\S+) (?P<ident>\S+) (?P<remote_user>\S+) \[(?P<time>[^\]]+)\] ` +
+				`"(?P<method>[^ "]+)(?: +(?P<path>[^ "]*))?(?: +(?P<protocol>[^ "]*))?" (?P<status>\d{3}) (?P<bytes>\d+|-)` +
+				`(?: "(?P<referer>(?:[^"\\]|\\.)*)" "(?P<user_agent>(?:[^"\\]|\\.)*)")?`),
+			nilValue: "-",
+			cols: []column{
+				{name: "remote_addr", pgType: dbv2.Text},
+				{name: "ident", pgType: dbv2.Text},
+				{name: "remote_user", pgType: dbv2.Text},
+				{name: "time", pgType: dbv2.TimestampTZ, convert: timeConverter(accessLogLayout, dbv2.TimestampTZ)},
+				{name: "method", pgType: dbv2.Text},
+				{name: "path", pgType: dbv2.Text},
+				{name: "protocol", pgType: dbv2.Text},
+				{name: "status", pgType: dbv2.Integer},
+				{name: "bytes", pgType: dbv2.BigInt},
+				{name: "referer", pgType: dbv2.Text},
+				{name: "user_agent", pgType: dbv2.Text},
+			},
+		}
+	},
+	// BSD syslog. The priority and the tag are optional, as files written by syslog daemons leave out the priority.
+	RFC3164: func() *pattern {
+		return &pattern{
+			re: regexp.MustCompile(`^(?:<(?P<priority>\d{1,3})>)?(?P<timestamp>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (?P<hostname>\S+) ` +
+				`(?:(?P<app_name>[^:\[\s]+)(?:\[(?P<procid>[^\]]+)\])?: ?)?(?P<message>.*)$`),
+			cols: []column{
+				{name: "priority", pgType: dbv2.SmallInt},
+				{name: "timestamp", pgType: dbv2.TimestampTZ, convert: rfc3164Time},
+				{name: "hostname", pgType: dbv2.Text},
+				{name: "app_name", pgType: dbv2.Text},
+				{name: "procid", pgType: dbv2.Text},
+				{name: "message", pgType: dbv2.Text},
+			},
+		}
+	},
+	// IETF syslog, where "-" marks a missing field.
+	RFC5424: func() *pattern {
+		return &pattern{
+			re: regexp.MustCompile(`^<(?P<priority>\d{1,3})>(?P<version>\d{1,2}) (?P<timestamp>\S+) (?P<hostname>\S+) (?P<app_name>\S+) ` +
+				`(?P<procid>\S+) (?P<msgid>\S+) (?P<structured_data>-|(?:\[(?:[^\]\\]|\\.)*\])+)(?: (?P<message>.*))?$`),
+			nilValue: "-",
+			cols: []column{
+				{name: "priority", pgType: dbv2.SmallInt},
+				{name: "version", pgType: dbv2.SmallInt},
+				{name: "timestamp", pgType: dbv2.TimestampTZ, convert: timeConverter(time.RFC3339Nano, dbv2.TimestampTZ)},
+				{name: "hostname", pgType: dbv2.Text},
+				{name: "app_name", pgType: dbv2.Text},
+				{name: "procid", pgType: dbv2.Text},
+				{name: "msgid", pgType: dbv2.Text},
+				{name: "structured_data", pgType: dbv2.Text},
+				{name: "message", pgType: dbv2.Text},
+			},
+		}
+	},
+}
+
+// newPattern returns a built-in pattern by name, or compiles val as a regex whose
+// named groups become the columns.
+func newPattern(val string) (*pattern, error) {
+	if builtin, ok := builtinPatterns[val]; ok {
+		return builtin(), nil
+	}
+	re, err := regexp.Compile(val)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q is neither a built-in pattern (%s, %s, %s) nor a valid regex: %v",
+			val, Combined, RFC3164, RFC5424, err)
+	}
+
+	p := &pattern{re: re}
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("pattern %q has no named groups, like (?P<status>\\d+), to take the columns from", val)
+}
+
+// groups returns the index of the group of every column.
+func (p *pattern) groups(cols []column) []int {
+	idx := make([]int, len(cols))
+	for i, col := range cols {
+		idx[i] = p.re.SubexpIndex(col.name)
+	}
+	return idx
+}
+
+// Layouts tried on the values of custom pattern groups, with the column type they give.
+var timeLayouts = []struct {
+	layout string
+	pgType string
+}{
+	{time.RFC3339Nano, dbv2.TimestampTZ},
+	{"2006-01-02 15:04:05Z07:00", dbv2.TimestampTZ},
+	{"2006-01-02 15:04:05 -0700", dbv2.TimestampTZ},
+	{accessLogLayout, dbv2.TimestampTZ},
+	{"2006-01-02T15:04:05", dbv2.Timestamp},
+	{"2006-01-02 15:04:05", dbv2.Timestamp},
+	{time.DateOnly, dbv2.Date},
+}
+
+// inferColumn types a custom pattern group from the values it matched in the looked up lines.
+func inferColumn(name string, values []string) column {
+	col := column{name: name, pgType: dbv2.Text}
+	if len(values) == 0 {
+		return col
+	}
+	if allValues(values, func(v string) bool { _, err := integerValue(v); return err == nil }) {
+		col.pgType, col.convert = dbv2.BigInt, integerValue
+		return col
+	}
+	if allValues(values, func(v string) bool { _, err := decimalValue(v); return err == nil }) {
+		col.pgType, col.convert = dbv2.Numeric, decimalValue
+		return col
+	}
+	for _, tl := range timeLayouts {
+		if allValues(values, func(v string) bool { _, err := time.Parse(tl.layout, v); return err == nil }) {
+			col.pgType, col.convert = tl.pgType, timeConverter(tl.layout, tl.pgType)
+			return col
+		}
+	}
+	return col
+}
+
+// integerValue checks a value of a BIGINT column, so lines with values like "-" or "n/a"
+// are rejected instead of failing the COPY of the whole file.
+func integerValue(val string) (string, error) {
+	_, err := strconv.ParseInt(val, 10, 64)
+	return val, err
+}
+
+// Decimal numbers as NUMERIC takes them, without the hex, NaN and Inf forms ParseFloat allows.
+var decimalNumber = regexp.MustCompile(`^[+-]?(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?$`)
+
+// decimalValue checks a value of a NUMERIC column, like integerValue.
+func decimalValue(val string) (string, error) {
+	if !decimalNumber.MatchString(val) {
+		return "", fmt.Errorf("%q is not a number", val)
+	}
+	return val, nil
+}
+
+func allValues(values []string, ok func(string) bool) bool {
+	for _, v := range values {
+		if !ok(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func timeConverter(layout, pgType string) func(string) (string, error) {
+	return func(val string) (string, error) {
+		t, err := time.Parse(layout, val)
+		if err != nil {
+			return "", err
+		}
+		return formatTime(t, pgType), nil
+	}
+}
+
+// rfc3164Time completes the timestamp, which has no year or zone, with the current year in the
+// local zone. Timestamps that would land in the future belong to last year, like December
+// lines read in January.
+func rfc3164Time(val string) (string, error) {
+	t, err := time.ParseInLocation(rfc3164Layout, val, time.Local)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	year := now.Year()
+	if time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local).After(now.Add(24 * time.Hour)) {
+		year--
+	}
+	t = time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+	return formatTime(t, dbv2.TimestampTZ), nil
+}
+
+func formatTime(t time.Time, pgType string) string {
+	switch pgType {
+	case dbv2.Date:
+		return t.Format(time.DateOnly)
+	case dbv2.Timestamp:
+		return t.Format("2006-01-02 15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -2,9 +2,11 @@ package dbv2
 
 import (
 	"context"
+	"database/sql/driver"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/stdlib"
@@ -16,12 +18,40 @@ const (
 	Numeric = "NUMERIC"
 	Text    = "TEXT"
 	Json    = "JSON"
+
+	// Types used when the source format carries its own schema.
+	SmallInt    = "INT2"
+	Integer     = "INT4"
+	BigInt      = "INT8"
+	Real        = "REAL"
+	Double      = "DOUBLE PRECISION"
+	Boolean     = "BOOLEAN"
+	Date        = "DATE"
+	Time        = "TIME"
+	Timestamp   = "TIMESTAMP"
+	TimestampTZ = "TIMESTAMPTZ"
+	Bytea       = "BYTEA"
+	UUID        = "UUID"
+	Jsonb       = "JSONB"
 )
 
+// Most connections open at once, below the max_connections of 100 that PostgreSQL has by
+// default. Loads wait for a connection once they're all in use.
+const maxOpenConns = 64
+
+// NumericOf returns a NUMERIC type with the given precision and scale.
+func NumericOf(precision, scale int32) string {
+	return fmt.Sprintf("%s(%d,%d)", Numeric, precision, scale)
+}
+
 type DB struct {
 	dbConn     *sqlx.DB
 	schema     string
 	resetTable bool
+	// Settings in place during LoadIn, like DateStyle = 'ISO'.
+	settings []string
+	// Tables that EnsureTable created, the only ones DeleteTable drops.
+	created *sync.Map
 }
 
 func NewPostgresDB(ctx context.Context, url, schema string, reset bool) (*DB, error) {
@@ -29,7 +59,24 @@ func NewPostgresDB(ctx context.Context, url, schema string, reset bool) (*DB, er
 	if err != nil {
 		return nil, errors.WithMessage(err, "failed to create db connection")
 	}
-	return &DB{dbConn, schema, reset}, nil
+	dbConn.SetMaxOpenConns(maxOpenConns)
+	return &DB{dbConn: dbConn, schema: schema, resetTable: reset, created: &sync.Map{}}, nil
+}
+
+// ForRun returns a DB on the same connections that keeps its own record of the tables it
+// created, so a failed run doesn't drop the tables that other runs created and loaded.
+func (d *DB) ForRun() *DB {
+	db := *d
+	db.created = &sync.Map{}
+	return &db
+}
+
+// WithSettings returns a DB on the same connections whose COPYs run with the settings, like
+// IntervalStyle = 'postgres'.
+func (d *DB) WithSettings(settings ...string) *DB {
+	db := *d
+	db.settings = settings
+	return &db
 }
 
 func (d *DB) GetRows(ctx context.Context, table string) error {
@@ -67,6 +114,7 @@ func (d *DB) EnsureTable(name string, tableSchema string) error {
 	createQuery := fmt.Sprintf("CREATE TABLE %s.%s %s", d.schema, name, tableSchema)
 	_, err := d.dbConn.Exec(createQuery)
 	if err == nil {
+		d.created.Store(name, true)
 		return nil
 	}
 
@@ -80,11 +128,26 @@ func (d *DB) EnsureTable(name string, tableSchema string) error {
 			return err
 		}
 	}
-	_, err = d.dbConn.Exec(createQuery)
-	return err
+	if _, err = d.dbConn.Exec(createQuery); err != nil {
+		return err
+	}
+	d.created.Store(name, true)
+	return nil
+}
+
+// HasExtension reports whether the extension, like postgis, is installed in the database.
+func (d *DB) HasExtension(name string) (bool, error) {
+	var exists bool
+	err := d.dbConn.Get(&exists, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = $1)", name)
+	return exists, err
 }
 
+// DeleteTable drops a table that EnsureTable created, after its load failed. Tables that
+// existed before, whose rows were loaded earlier, are kept.
 func (d *DB) DeleteTable(name string) error {
+	if _, ok := d.created.LoadAndDelete(name); !ok {
+		return nil
+	}
 	_, err := d.dbConn.Exec(fmt.Sprintf("DROP TABLE %s.%s", d.schema, name))
 	return err
 }
@@ -94,16 +157,50 @@ func (d *DB) LoadIn(ctx context.Context, r io.Reader, copyCmd string) (int64, er
 	if err != nil {
 		return 0, err
 	}
+	defer conn.Close()
 	var res pgconn.CommandTag
 	err = conn.Raw(func(driverConn any) error {
 		pgCon := driverConn.(*stdlib.Conn).Conn().PgConn()
+		if len(d.settings) > 0 {
+			// SET LOCAL lasts until the end of the transaction, so the connection goes back to
+			// the pool as it was.
+			if err = pgCon.Exec(ctx, "BEGIN; SET LOCAL "+strings.Join(d.settings, "; SET LOCAL ")).Close(); err != nil {
+				return endTransaction(ctx, pgCon, err)
+			}
+		}
 		// Use PostgreSQL's COPY command for efficient data loading.
 		res, err = pgCon.CopyFrom(ctx, r, copyCmd)
+		if len(d.settings) > 0 {
+			err = endTransaction(ctx, pgCon, err)
+		}
 		return err
 	})
 	return res.RowsAffected(), err
 }
 
+// endTransaction commits the transaction of LoadIn, or rolls it back after err. A connection
+// that is still in the transaction is reported as bad, so database/sql drops it instead of
+// handing it to the next statement.
+func endTransaction(ctx context.Context, pgCon *pgconn.PgConn, err error) error {
+	end := "COMMIT"
+	if err != nil {
+		end = "ROLLBACK"
+	}
+	if endErr := pgCon.Exec(context.WithoutCancel(ctx), end).Close(); err == nil {
+		err = endErr
+	}
+	if err != nil && pgCon.TxStatus() != 'I' {
+		err = fmt.Errorf("%w: %w", err, driver.ErrBadConn)
+	}
+	return err
+}
+
+// MaxOpenConns returns the most connections open at once, which bounds the COPYs that can
+// run side by side.
+func (d *DB) MaxOpenConns() int {
+	return maxOpenConns
+}
+
 func (d *DB) Schema() string {
 	return d.schema
 }
@@ -0,0 +1,234 @@
+package postgresloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	builterr "errors"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/jackc/pgx/v5"
+)
+
+// Scheme of the source table arguments, like pg://user@host:5432/shop/public.orders.
+const sourceScheme = "pg://"
+
+// Settings of both sides of a copy, so that the values print the same whatever the defaults
+// of the source are, and read back as they were: ISO dates, intervals in the format COPY
+// parses, and floats with all their digits.
+var sessionSettings = []string{"DateStyle = 'ISO'", "IntervalStyle = 'postgres'", "extra_float_digits = 3"}
+
+// Source is a query on another PostgreSQL database whose result is loaded into a table.
+type Source struct {
+	DSN   string
+	Query string
+	// Target table.
+	Table string
+	// Shown in the status lines, without the password.
+	Name string
+}
+
+type column struct {
+	name   string
+	pgType string
+}
+
+type PostgresLoader struct {
+	maxConcurrency int
+	typeSetting    string
+
+	sources []Source
+
+	db *dbv2.DB
+}
+
+func New(sources []Source, db *dbv2.DB, concurrency int, t string) *PostgresLoader {
+	return &PostgresLoader{
+		maxConcurrency: concurrency,
+		typeSetting:    t,
+		sources:        sources,
+		db:             db.WithSettings(sessionSettings...),
+	}
+}
+
+// IsSourceURL reports whether the argument is a source table, like pg://host/db/schema.table.
+func IsSourceURL(arg string) bool {
+	return strings.HasPrefix(arg, sourceScheme)
+}
+
+// ParseSourceURL turns pg://[user[:password]@]host[:port]/db/[schema.]table[?params] into the
+// source of the whole table, loaded into a table of the same name.
+func ParseSourceURL(arg string) (Source, error) {
+	u, err := url.Parse(arg)
+	if err != nil {
+		return Source{}, err
+	}
+	db, table, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if !ok || db == "" || table == "" || strings.Contains(table, "/") {
+		return Source{}, fmt.Errorf("%s isn't like pg://host/db/schema.table", arg)
+	}
+
+	ident := pgx.Identifier{table}
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		ident, table = pgx.Identifier{schema, name}, name
+	}
+	u.Scheme, u.Path = "postgres", "/"+db
+	name := *u
+	name.User, name.Scheme = nil, "pg"
+	name.Path += "/" + strings.Join(ident, ".")
+	return Source{
+		DSN:   u.String(),
+		Query: "SELECT * FROM " + ident.Sanitize(),
+		Table: shared.GetObjectTableName(table),
+		Name:  name.String(),
+	}, nil
+}
+
+// RedactDSN returns the DSN without its password, for the status lines.
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	var fields []string
+	for _, field := range strings.Fields(dsn) {
+		if strings.HasPrefix(field, "password=") {
+			field = "password=xxxxx"
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, " ")
+}
+
+func (p *PostgresLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, failed int64
+	start := time.Now()
+
+	var (
+		errs    []error
+		names   []string
+		sources = map[string]Source{}
+	)
+	for _, src := range p.sources {
+		if other, exists := sources[src.Table]; exists {
+			err := fmt.Errorf("%s is also loaded into %s", other.Name, src.Table)
+			printError(src, err)
+			errs = append(errs, err)
+			continue
+		}
+		sources[src.Table] = src
+		names = append(names, src.Table)
+	}
+	failed = int64(len(errs))
+	total := len(names) + len(errs)
+
+	err := shared.RunInParallel(p.maxConcurrency, names, func(name string) error {
+		var err error
+
+		src := sources[name]
+		defer func() {
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = p.db.DeleteTable(name)
+			}
+		}()
+
+		rowsInserted, err := p.load(ctx, src)
+		if err != nil {
+			printError(src, err)
+			return err
+		}
+		atomic.AddInt64(&totalRowsInserted, rowsInserted)
+		fmt.Printf("status=SUCCESS rows_inserted=%s source=%q table=%s\n", shared.FormatNumber(rowsInserted), src.Name, name)
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"POSTGRES", total, total-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, builterr.Join(append(errs, err)...)
+}
+
+// load streams the result of the source query, with COPY (query) TO STDOUT on the source
+// database, into COPY FROM STDIN on the target one. Both sides use the text format, so
+// the values are loaded as the source prints them, with the sessionSettings on both.
+func (p *PostgresLoader) load(ctx context.Context, src Source) (int64, error) {
+	conn, err := pgx.Connect(ctx, src.DSN)
+	if err != nil {
+		return 0, fmt.Errorf("unable to connect to the source database: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+	if _, err = conn.Exec(ctx, "SET "+strings.Join(sessionSettings, "; SET ")); err != nil {
+		return 0, fmt.Errorf("unable to set up the source session: %w", err)
+	}
+
+	query := strings.TrimRight(strings.TrimSpace(src.Query), "; \t\n")
+	cols, err := p.columns(ctx, conn, query)
+	if err != nil {
+		return 0, err
+	}
+
+	colsTypes := make([]string, len(cols))
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		colsTypes[i] = strconv.Quote(col.name) + " " + col.pgType
+		names[i] = col.name
+	}
+	// Ensure the table exists or create it if necessary.
+	if err = p.db.EnsureTable(src.Table, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return 0, err
+	}
+
+	return csv2.LoadTextStream(ctx, src.Table, p.db, names, func(w io.Writer) error {
+		_, err := conn.PgConn().CopyTo(ctx, w, fmt.Sprintf("COPY (%s) TO STDOUT", query))
+		return err
+	})
+}
+
+// columns returns the columns of the query result with their source types, like
+// numeric(12,2) or timestamp with time zone. Types that aren't built in, like enums, domains
+// and the types of extensions, which the target may not have, become TEXT.
+func (p *PostgresLoader) columns(ctx context.Context, conn *pgx.Conn, query string) ([]column, error) {
+	// The unnamed statement, which only describes the result without running the query.
+	desc, err := conn.PgConn().Prepare(ctx, "", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]int{}
+	cols := make([]column, len(desc.Fields))
+	for i, field := range desc.Fields {
+		name := field.Name
+		if seen[name]++; seen[name] > 1 {
+			// Like the two id columns of a join.
+			name = fmt.Sprintf("%s_%d", name, seen[name])
+		}
+		cols[i] = column{name: name, pgType: dbv2.Text}
+		if p.typeSetting == shared.AllText {
+			continue
+		}
+
+		var builtin bool
+		err := conn.QueryRow(ctx,
+			`SELECT format_type(t.oid, $2), n.nspname = 'pg_catalog' FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace WHERE t.oid = $1`,
+			field.DataTypeOID, field.TypeModifier,
+		).Scan(&cols[i].pgType, &builtin)
+		if err != nil {
+			return nil, fmt.Errorf("type of column %q: %w", field.Name, err)
+		}
+		if !builtin {
+			cols[i].pgType = dbv2.Text
+		}
+	}
+	return cols, nil
+}
+
+func printError(src Source, err error) {
+	fmt.Printf(`status=FAILED data_format="POSTGRES" msg="unable to load" source=%q name=%q error=%q`+"\n", src.Name, src.Table, err.Error())
+}
@@ -0,0 +1,202 @@
+package protoloader
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const timestampName = "google.protobuf.Timestamp"
+
+// Wrapper messages, which are stored as the value they wrap.
+var wrapperNames = map[protoreflect.FullName]bool{
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+type column struct {
+	fd     protoreflect.FieldDescriptor
+	pgType string
+	// Repeated fields, maps and nested messages are stored as JSON.
+	json bool
+}
+
+// columns are the top-level fields of the message type, in the order they are declared.
+type columns struct {
+	cols    []column
+	hasJSON bool
+	marshal protojson.MarshalOptions
+}
+
+func newColumns(md protoreflect.MessageDescriptor, types *dynamicpb.Types, typeSetting string) *columns {
+	c := &columns{marshal: protojson.MarshalOptions{UseProtoNames: true, Resolver: types}}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		pgType, isJSON := fieldType(fd)
+		if typeSetting == shared.AllText {
+			pgType = dbv2.Text
+		}
+		c.cols = append(c.cols, column{fd: fd, pgType: pgType, json: isJSON})
+		c.hasJSON = c.hasJSON || isJSON
+	}
+	return c
+}
+
+func (c *columns) names() []string {
+	names := make([]string, len(c.cols))
+	for i, col := range c.cols {
+		names[i] = string(col.fd.Name())
+	}
+	return names
+}
+
+func (c *columns) types() []string {
+	colsTypes := make([]string, len(c.cols))
+	for i, col := range c.cols {
+		colsTypes[i] = strconv.Quote(string(col.fd.Name())) + " " + col.pgType
+	}
+	return colsTypes
+}
+
+// fieldType returns the PostgreSQL type of the field and whether it's stored as JSON.
+func fieldType(fd protoreflect.FieldDescriptor) (string, bool) {
+	if fd.IsList() || fd.IsMap() {
+		return dbv2.Jsonb, true
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return dbv2.Boolean, false
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return dbv2.Integer, false
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return dbv2.BigInt, false
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return dbv2.NumericOf(20, 0), false
+	case protoreflect.FloatKind:
+		return dbv2.Real, false
+	case protoreflect.DoubleKind:
+		return dbv2.Double, false
+	case protoreflect.BytesKind:
+		return dbv2.Bytea, false
+	case protoreflect.StringKind, protoreflect.EnumKind:
+		// Enums are stored by their value names.
+		return dbv2.Text, false
+	}
+
+	md := fd.Message()
+	switch {
+	case md.FullName() == timestampName:
+		return dbv2.TimestampTZ, false
+	case wrapperNames[md.FullName()]:
+		return fieldType(md.Fields().ByName("value"))
+	}
+	return dbv2.Jsonb, true
+}
+
+// writeRow writes the message as a row in the text format of COPY. Fields that track presence
+// and aren't set are nulls, other fields take their default values like protobuf does.
+func (c *columns) writeRow(bw *bufio.Writer, msg *dynamicpb.Message) error {
+	var jsonFields map[string]json.RawMessage
+	if c.hasJSON {
+		b, err := c.marshal.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(b, &jsonFields); err != nil {
+			return err
+		}
+	}
+
+	for i, col := range c.cols {
+		if i > 0 {
+			bw.WriteByte('\t')
+		}
+		fd := col.fd
+		switch {
+		case col.json:
+			raw, ok := jsonFields[string(fd.Name())]
+			switch {
+			case ok:
+				bw.WriteString(csvutils.CopyTextEscaper.Replace(string(raw)))
+			case fd.IsList():
+				bw.WriteString("[]")
+			case fd.IsMap():
+				bw.WriteString("{}")
+			default:
+				bw.WriteString(csvutils.CopyTextNull)
+			}
+		case fd.HasPresence() && !msg.Has(fd):
+			bw.WriteString(csvutils.CopyTextNull)
+		default:
+			bw.WriteString(valueText(fd, msg.Get(fd)))
+		}
+	}
+	return bw.WriteByte('\n')
+}
+
+// valueText encodes a scalar value, timestamp or wrapper message in the text format of COPY.
+func valueText(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	case protoreflect.FloatKind:
+		return formatFloat(v.Float(), 32)
+	case protoreflect.DoubleKind:
+		return formatFloat(v.Float(), 64)
+	case protoreflect.StringKind:
+		return csvutils.CopyTextEscaper.Replace(v.String())
+	case protoreflect.BytesKind:
+		return csvutils.CopyTextEscaper.Replace(`\x` + hex.EncodeToString(v.Bytes()))
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		// Values added after the descriptor set was compiled.
+		return strconv.Itoa(int(v.Enum()))
+	}
+
+	m := v.Message()
+	fields := m.Descriptor().Fields()
+	if m.Descriptor().FullName() == timestampName {
+		t := time.Unix(m.Get(fields.ByName("seconds")).Int(), m.Get(fields.ByName("nanos")).Int())
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	valueFd := fields.ByName("value")
+	return valueText(valueFd, m.Get(valueFd))
+}
+
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
@@ -0,0 +1,156 @@
+package protoloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type ProtoLoader struct {
+	maxConcurrency int
+
+	typeSetting string
+	// Compiled descriptor set (protoc --descriptor_set_out) holding the message type.
+	descriptorSet string
+	// Full name of the message type of the files, like events.v1.Click.
+	messageName string
+
+	filesList []string
+
+	db *dbv2.DB
+}
+
+func New(files []string, db *dbv2.DB, concurrency int, t, descriptorSet, messageName string) *ProtoLoader {
+	return &ProtoLoader{
+		maxConcurrency: concurrency,
+		typeSetting:    t,
+		descriptorSet:  descriptorSet,
+		messageName:    messageName,
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (p *ProtoLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, failed int64
+	start := time.Now()
+
+	md, types, err := p.loadMessageType()
+	if err != nil {
+		return "", err
+	}
+	cols := newColumns(md, types, p.typeSetting)
+
+	err = shared.RunInParallel(p.maxConcurrency, p.filesList, func(file string) error {
+		var err error
+
+		name := shared.GetTableName(file)
+		defer func() {
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = p.db.DeleteTable(name)
+			}
+		}()
+
+		rowsInserted, err := p.load(ctx, md, cols, file, name)
+		if err != nil {
+			printError(file, name, err)
+			return err
+		}
+		atomic.AddInt64(&totalRowsInserted, rowsInserted)
+		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s\n",
+			shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file)
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"PROTOBUF", len(p.filesList), len(p.filesList)-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+// loadMessageType finds the message type in the descriptor set, along with the types that
+// nested messages and Any fields resolve to when stored as JSON.
+func (p *ProtoLoader) loadMessageType() (protoreflect.MessageDescriptor, *dynamicpb.Types, error) {
+	if p.descriptorSet == "" || p.messageName == "" {
+		return nil, nil, fmt.Errorf("protobuf files need both --descriptor-set and --message")
+	}
+	data, err := os.ReadFile(p.descriptorSet)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fds descriptorpb.FileDescriptorSet
+	if err = proto.Unmarshal(data, &fds); err != nil {
+		return nil, nil, fmt.Errorf("invalid descriptor set %s: %v", p.descriptorSet, err)
+	}
+	files, err := protodesc.NewFiles(&fds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid descriptor set %s, it needs the imported files too (protoc --include_imports): %v", p.descriptorSet, err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(p.messageName, ".")))
+	if err != nil {
+		if err == protoregistry.NotFound {
+			err = fmt.Errorf("message %q not found in %s", p.messageName, p.descriptorSet)
+		}
+		return nil, nil, err
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("%q is not a message in %s", p.messageName, p.descriptorSet)
+	}
+	return md, dynamicpb.NewTypes(files), nil
+}
+
+func (p *ProtoLoader) load(ctx context.Context, md protoreflect.MessageDescriptor, cols *columns, file, name string) (int64, error) {
+	// Ensure the table exists or create it if necessary.
+	if err := p.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(cols.types(), ", "))); err != nil {
+		return 0, err
+	}
+
+	return csv2.LoadTextStream(ctx, name, p.db, cols.names(), func(w io.Writer) error {
+		r, err := reader.NewFileGzipReaderContext(ctx, file)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		br := bufio.NewReaderSize(r, 64*1024)
+		bw := bufio.NewWriterSize(w, 64*1024)
+		opts := protodelim.UnmarshalOptions{MaxSize: -1}
+		for msgs := 0; ; msgs++ {
+			msg := dynamicpb.NewMessage(md)
+			err := opts.UnmarshalFrom(br, msg)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("message %d: %w", msgs+1, err)
+			}
+			if err = cols.writeRow(bw, msg); err != nil {
+				return fmt.Errorf("message %d: %w", msgs+1, err)
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+func printError(f, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="PROTOBUF" msg="unable to load" file=%q name=%q error=%q`+"\n", f, name, err.Error())
+}
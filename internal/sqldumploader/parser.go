@@ -0,0 +1,777 @@
+package sqldumploader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	// End of a statement, the current delimiter.
+	tokEnd
+	tokWord
+	// Identifier quoted with backticks, double quotes or brackets.
+	tokIdent
+	tokString
+	tokNumber
+	// X'..' and 0x.. literals, the text holds the hex digits.
+	tokHex
+	// B'..' literals, the text holds the binary digits.
+	tokBits
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// Quote of a tokIdent.
+	quote byte
+}
+
+func (t token) isWord(word string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+func (t token) isPunct(c string) bool {
+	return t.kind == tokPunct && t.text == c
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokEnd:
+		return "end of statement"
+	case tokString:
+		return "string"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexer splits a dump into tokens without keeping more than the current token in memory.
+type lexer struct {
+	r     *bufio.Reader
+	delim string
+	// MySQL treats backslashes in strings as escapes, standard SQL doesn't.
+	backslash bool
+
+	peeked *token
+}
+
+func newLexer(r io.Reader) *lexer {
+	return &lexer{r: bufio.NewReaderSize(r, 256*1024), delim: ";", backslash: true}
+}
+
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		t, err := l.next()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+func (l *lexer) next() (token, error) {
+	if l.peeked != nil {
+		t := *l.peeked
+		l.peeked = nil
+		return t, nil
+	}
+	if err := l.skipSpace(); err != nil {
+		if err == io.EOF {
+			return token{kind: tokEOF}, nil
+		}
+		return token{}, err
+	}
+	if b, _ := l.r.Peek(len(l.delim)); string(b) == l.delim {
+		_, _ = l.r.Discard(len(l.delim))
+		return token{kind: tokEnd}, nil
+	}
+
+	c, err := l.r.ReadByte()
+	if err != nil {
+		return token{}, err
+	}
+	switch {
+	case c == '\'':
+		s, err := l.readString(l.backslash)
+		return token{kind: tokString, text: s}, err
+	case c == '"' || c == '`':
+		s, err := l.readQuoted(c, c)
+		return token{kind: tokIdent, text: s, quote: c}, err
+	case c == '[':
+		s, err := l.readQuoted('[', ']')
+		return token{kind: tokIdent, text: s, quote: c}, err
+	case isDigit(c) || c == '.' && l.peekDigit():
+		return l.readNumber(c)
+	case c == '$' && l.dollarTag() != "":
+		s, err := l.readDollarQuoted("$" + l.dollarTag())
+		return token{kind: tokString, text: s}, err
+	case isWordByte(c):
+		return l.readWord(c)
+	}
+	return token{kind: tokPunct, text: string(c)}, nil
+}
+
+// skipSpace skips white space and comments, including MySQL's /*! ... */ ones that only
+// set session variables in dumps.
+func (l *lexer) skipSpace() error {
+	for {
+		b, err := l.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		switch c := b[0]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			_, _ = l.r.Discard(1)
+		case c == '#' || c == '-' && l.peekIs("--"):
+			if _, err = l.r.ReadString('\n'); err != nil {
+				return err
+			}
+		case c == '/' && l.peekIs("/*"):
+			_, _ = l.r.Discard(2)
+			if err = l.skipBlockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (l *lexer) skipBlockComment() error {
+	for {
+		_, err := l.r.ReadSlice('*')
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if l.peekIs("/") {
+			_, _ = l.r.Discard(1)
+			return nil
+		}
+	}
+}
+
+func (l *lexer) peekIs(s string) bool {
+	b, _ := l.r.Peek(len(s))
+	return string(b) == s
+}
+
+func (l *lexer) peekDigit() bool {
+	b, _ := l.r.Peek(1)
+	return len(b) == 1 && isDigit(b[0])
+}
+
+// readString reads a single quoted string after its opening quote.
+func (l *lexer) readString(backslash bool) (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		switch {
+		case c == '\'':
+			if !l.peekIs("'") {
+				return sb.String(), nil
+			}
+			_, _ = l.r.Discard(1)
+			sb.WriteByte('\'')
+		case c == '\\' && backslash:
+			if c, err = l.r.ReadByte(); err != nil {
+				return "", unexpectedEOF(err)
+			}
+			sb.WriteString(unescape(c))
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// unescape follows https://dev.mysql.com/doc/refman/8.0/en/string-literals.html.
+func unescape(c byte) string {
+	switch c {
+	case '0':
+		return "\x00"
+	case 'b':
+		return "\b"
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case 'Z':
+		return "\x1a"
+	case '%', '_':
+		// Kept for LIKE patterns.
+		return `\` + string(c)
+	}
+	return string(c)
+}
+
+// readQuoted reads a quoted identifier, where a doubled closing quote stands for itself.
+func (l *lexer) readQuoted(open, close byte) (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		if c != close {
+			sb.WriteByte(c)
+			continue
+		}
+		if open == close && l.peekIs(string(close)) {
+			_, _ = l.r.Discard(1)
+			sb.WriteByte(c)
+			continue
+		}
+		return sb.String(), nil
+	}
+}
+
+// dollarTag returns the rest of the opening tag of a dollar quoted string after its first $,
+// like body$ of $body$ or $ of $$, or "" when the $ doesn't start one, like in $1.
+func (l *lexer) dollarTag() string {
+	for n := 1; ; n++ {
+		b, err := l.r.Peek(n)
+		if err != nil {
+			return ""
+		}
+		switch c := b[n-1]; {
+		case c == '$':
+			return string(b)
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 || n > 1 && isDigit(c):
+		default:
+			return ""
+		}
+	}
+}
+
+// readDollarQuoted reads a dollar quoted string, like the $$ ... $$ bodies of functions that
+// pg_dump writes, as one string, since semicolons and quotes inside it don't end anything.
+func (l *lexer) readDollarQuoted(tag string) (string, error) {
+	_, _ = l.r.Discard(len(tag) - 1)
+	var b []byte
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		b = append(b, c)
+		if c == '$' && bytes.HasSuffix(b, []byte(tag)) {
+			return string(b[:len(b)-len(tag)]), nil
+		}
+	}
+}
+
+func (l *lexer) readNumber(first byte) (token, error) {
+	if first == '0' && (l.peekIs("x") || l.peekIs("X")) {
+		_, _ = l.r.Discard(1)
+		return token{kind: tokHex, text: l.readWhile(isHexDigit)}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteByte(first)
+	for {
+		b, _ := l.r.Peek(1)
+		if len(b) == 0 {
+			break
+		}
+		c := b[0]
+		if !isDigit(c) && c != '.' && c != 'e' && c != 'E' {
+			break
+		}
+		_, _ = l.r.Discard(1)
+		sb.WriteByte(c)
+		if (c == 'e' || c == 'E') && (l.peekIs("-") || l.peekIs("+")) {
+			s, _ := l.r.ReadByte()
+			sb.WriteByte(s)
+		}
+	}
+	return token{kind: tokNumber, text: sb.String()}, nil
+}
+
+func (l *lexer) readWord(first byte) (token, error) {
+	word := string(first) + l.readWhile(isWordByte)
+	if !l.peekIs("'") {
+		return token{kind: tokWord, text: word}, nil
+	}
+
+	// Prefixed strings: X'4D7953514C', B'101', E'escaped\n', N'national' and _utf8mb4'text'.
+	_, _ = l.r.Discard(1)
+	switch lower := strings.ToLower(word); {
+	case lower == "x":
+		s, err := l.readString(false)
+		return token{kind: tokHex, text: s}, err
+	case lower == "b":
+		s, err := l.readString(false)
+		return token{kind: tokBits, text: s}, err
+	case lower == "e":
+		s, err := l.readString(true)
+		return token{kind: tokString, text: s}, err
+	case lower == "n" || strings.HasPrefix(lower, "_"):
+		s, err := l.readString(l.backslash)
+		return token{kind: tokString, text: s}, err
+	}
+	return token{}, fmt.Errorf("unexpected string after %q", word)
+}
+
+func (l *lexer) readWhile(ok func(byte) bool) string {
+	var sb strings.Builder
+	for {
+		b, _ := l.r.Peek(1)
+		if len(b) == 0 || !ok(b[0]) {
+			return sb.String()
+		}
+		_, _ = l.r.Discard(1)
+		sb.WriteByte(b[0])
+	}
+}
+
+// readLine reads the rest of the current line, used for MySQL's DELIMITER command.
+func (l *lexer) readLine() (string, error) {
+	line, err := l.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '$' || c >= 0x80
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type statementKind int
+
+const (
+	stmtCreate statementKind = iota
+	stmtInsert
+)
+
+// statement is a CREATE TABLE, or the start of an INSERT whose rows are read with rows.
+type statement struct {
+	kind statementKind
+	// Table name in the dump, without its schema or database.
+	table string
+	// Columns of the CREATE TABLE.
+	cols []column
+	// Columns listed in the INSERT, empty for all columns of the table.
+	insertCols []string
+}
+
+// sameInsert reports whether the rows of both INSERTs can go through the same COPY.
+func (s *statement) sameInsert(other *statement) bool {
+	return other != nil && other.kind == stmtInsert && other.table == s.table && slices.Equal(other.insertCols, s.insertCols)
+}
+
+type valueKind int
+
+const (
+	valNull valueKind = iota
+	valString
+	valNumber
+	valBool
+	valHex
+	valBits
+)
+
+type value struct {
+	kind valueKind
+	text string
+}
+
+// dumpParser reads the CREATE TABLE and INSERT statements of a dump, skipping everything else.
+type dumpParser struct {
+	lex    *lexer
+	values []value
+}
+
+func newDumpParser(r io.Reader) *dumpParser {
+	return &dumpParser{lex: newLexer(r)}
+}
+
+// next returns the next CREATE TABLE or INSERT statement, or io.EOF at the end of the dump.
+func (p *dumpParser) next() (*statement, error) {
+	for {
+		t, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+
+		var stmt *statement
+		switch {
+		case t.kind == tokEOF:
+			return nil, io.EOF
+		case t.kind == tokEnd:
+			continue
+		case t.isWord("create"):
+			stmt, err = p.createTable()
+		case t.isWord("insert"), t.isWord("replace"):
+			stmt, err = p.insert()
+		case t.isWord("delimiter"):
+			// Dumps with routines and triggers switch to another delimiter, like ;; or $$.
+			if p.lex.delim, err = p.lex.readLine(); err == nil && p.lex.delim == "" {
+				err = fmt.Errorf("DELIMITER without a delimiter")
+			}
+		case t.isWord("set"):
+			err = p.set()
+		case t.isWord("copy"):
+			err = fmt.Errorf("COPY ... FROM stdin blocks aren't supported, restore the dump with psql instead")
+		default:
+			err = p.skipStatement()
+		}
+		if err != nil || stmt != nil {
+			return stmt, err
+		}
+	}
+}
+
+// set looks for pg_dump's `SET standard_conforming_strings = on`, after which backslashes in
+// strings are just backslashes.
+func (p *dumpParser) set() error {
+	var words []string
+	for {
+		t, err := p.lex.next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokEnd || t.kind == tokEOF {
+			break
+		}
+		if t.kind == tokWord || t.kind == tokString {
+			words = append(words, strings.ToLower(t.text))
+		}
+	}
+	if len(words) == 2 && words[0] == "standard_conforming_strings" {
+		p.lex.backslash = words[1] != "on"
+	}
+	return nil
+}
+
+func (p *dumpParser) skipStatement() error {
+	for {
+		t, err := p.lex.next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokEnd || t.kind == tokEOF {
+			return nil
+		}
+	}
+}
+
+// createTable parses CREATE TABLE statements. Other CREATE statements are skipped.
+func (p *dumpParser) createTable() (*statement, error) {
+	t, err := p.lex.next()
+	for err == nil && !t.isWord("table") {
+		if !t.isWord("temporary") && !t.isWord("temp") && !t.isWord("unlogged") && !t.isWord("global") && !t.isWord("local") {
+			return nil, p.skipStatement()
+		}
+		t, err = p.lex.next()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &statement{kind: stmtCreate}
+	if stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	// CREATE TABLE ... AS SELECT and CREATE TABLE ... LIKE have no column list.
+	if t, err = p.lex.next(); err != nil || !t.isPunct("(") {
+		if err != nil {
+			return nil, err
+		}
+		return nil, p.skipStatement()
+	}
+
+	for {
+		def, end, err := p.columnDef()
+		if err != nil {
+			return nil, err
+		}
+		if col, ok := parseColumn(def); ok {
+			stmt.cols = append(stmt.cols, col)
+		}
+		if end {
+			break
+		}
+	}
+	if len(stmt.cols) == 0 {
+		return nil, fmt.Errorf("table %q has no columns", stmt.table)
+	}
+	// Table options, like ENGINE=InnoDB.
+	return stmt, p.skipStatement()
+}
+
+// tableName reads a table name, skipping IF NOT EXISTS and the schema or database.
+func (p *dumpParser) tableName() (string, error) {
+	t, err := p.lex.next()
+	if err != nil {
+		return "", err
+	}
+	if t.isWord("if") {
+		for _, word := range []string{"not", "exists"} {
+			if t, err = p.lex.next(); err != nil {
+				return "", err
+			}
+			if !t.isWord(word) {
+				return "", fmt.Errorf("expected %s, found %s", strings.ToUpper(word), t)
+			}
+		}
+		if t, err = p.lex.next(); err != nil {
+			return "", err
+		}
+	}
+
+	for {
+		if t.kind != tokWord && t.kind != tokIdent {
+			return "", fmt.Errorf("expected a table name, found %s", t)
+		}
+		next, err := p.lex.peek()
+		if err != nil || !next.isPunct(".") {
+			return t.text, err
+		}
+		_, _ = p.lex.next()
+		if t, err = p.lex.next(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// columnDef returns the tokens of the next column or constraint definition and whether it
+// was the last one.
+func (p *dumpParser) columnDef() ([]token, bool, error) {
+	var (
+		def   []token
+		depth int
+	)
+	for {
+		t, err := p.lex.next()
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case t.kind == tokEnd || t.kind == tokEOF:
+			return nil, false, fmt.Errorf("unexpected %s in column definitions", t)
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			if depth == 0 {
+				return def, true, nil
+			}
+			depth--
+		case t.isPunct(",") && depth == 0:
+			return def, false, nil
+		}
+		def = append(def, t)
+	}
+}
+
+func (p *dumpParser) insert() (*statement, error) {
+	t, err := p.lex.next()
+	for err == nil && t.kind == tokWord && slices.Contains([]string{"low_priority", "delayed", "high_priority", "ignore", "into"}, strings.ToLower(t.text)) {
+		t, err = p.lex.next()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.lex.peeked = &t
+
+	stmt := &statement{kind: stmtInsert}
+	if stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if t, err = p.lex.next(); err != nil {
+		return nil, err
+	}
+	if t.isPunct("(") {
+		if stmt.insertCols, err = p.identList(); err != nil {
+			return nil, err
+		}
+		if t, err = p.lex.next(); err != nil {
+			return nil, err
+		}
+	}
+	if !t.isWord("values") && !t.isWord("value") {
+		return nil, fmt.Errorf("only INSERT ... VALUES is supported, found %s after INSERT INTO %q", t, stmt.table)
+	}
+	return stmt, nil
+}
+
+// identList reads identifiers up to the closing parenthesis.
+func (p *dumpParser) identList() ([]string, error) {
+	var idents []string
+	for {
+		t, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind != tokWord && t.kind != tokIdent {
+			return nil, fmt.Errorf("expected a column name, found %s", t)
+		}
+		idents = append(idents, t.text)
+
+		if t, err = p.lex.next(); err != nil {
+			return nil, err
+		}
+		switch {
+		case t.isPunct(")"):
+			return idents, nil
+		case !t.isPunct(","):
+			return nil, fmt.Errorf("expected , or ) in column list, found %s", t)
+		}
+	}
+}
+
+// rows calls fn with the values of every row of the current INSERT, one row at a time.
+// The values are only valid until fn returns.
+func (p *dumpParser) rows(fn func(values []value) error) error {
+	for {
+		t, err := p.lex.next()
+		if err != nil {
+			return err
+		}
+		if !t.isPunct("(") {
+			return fmt.Errorf("expected ( before the row values, found %s", t)
+		}
+
+		p.values = p.values[:0]
+		for {
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			p.values = append(p.values, v)
+
+			if t, err = p.lex.next(); err != nil {
+				return err
+			}
+			if t.isPunct(")") {
+				break
+			}
+			if !t.isPunct(",") {
+				return fmt.Errorf("expected , or ) between row values, found %s", t)
+			}
+		}
+		if err = fn(p.values); err != nil {
+			return err
+		}
+
+		if t, err = p.lex.next(); err != nil {
+			return err
+		}
+		switch {
+		case t.isPunct(","):
+		case t.kind == tokEnd || t.kind == tokEOF:
+			return nil
+		case t.isWord("on"):
+			// ON DUPLICATE KEY UPDATE and ON CONFLICT don't matter for new tables.
+			return p.skipStatement()
+		default:
+			return fmt.Errorf("expected , or the end of the INSERT after a row, found %s", t)
+		}
+	}
+}
+
+func (p *dumpParser) value() (value, error) {
+	t, err := p.lex.next()
+	if err != nil {
+		return value{}, err
+	}
+
+	var v value
+	switch {
+	case t.kind == tokString, t.kind == tokIdent && t.quote == '"':
+		// MySQL uses double quotes for strings too.
+		v = value{kind: valString, text: t.text}
+	case t.kind == tokNumber:
+		v = value{kind: valNumber, text: t.text}
+	case t.kind == tokHex:
+		v = value{kind: valHex, text: t.text}
+	case t.kind == tokBits:
+		v = value{kind: valBits, text: t.text}
+	case t.isPunct("-"), t.isPunct("+"):
+		n, err := p.lex.next()
+		if err != nil {
+			return value{}, err
+		}
+		if n.kind != tokNumber {
+			return value{}, fmt.Errorf("expected a number after %s, found %s", t, n)
+		}
+		v = value{kind: valNumber, text: strings.TrimPrefix(t.text, "+") + n.text}
+	case t.isWord("null"):
+		v = value{kind: valNull}
+	case t.isWord("true"), t.isWord("false"):
+		v = value{kind: valBool, text: strings.ToLower(t.text)}
+	case t.kind == tokWord && strings.HasPrefix(t.text, "_"):
+		// Character set introducers, like _binary 'data'.
+		return p.value()
+	default:
+		return value{}, fmt.Errorf("unsupported value %s, only literals are supported in VALUES", t)
+	}
+	return v, p.skipCast()
+}
+
+// skipCast skips PostgreSQL casts, like '2024-01-02'::date or 'happy'::public.mood.
+func (p *dumpParser) skipCast() error {
+	for {
+		t, err := p.lex.peek()
+		if err != nil || !t.isPunct(":") {
+			return err
+		}
+		_, _ = p.lex.next()
+		if t, err = p.lex.next(); err != nil {
+			return err
+		}
+		if !t.isPunct(":") {
+			return fmt.Errorf("expected :: cast, found %s", t)
+		}
+
+		depth := 0
+		for {
+			if t, err = p.lex.peek(); err != nil {
+				return err
+			}
+			switch {
+			case t.isPunct("("):
+				depth++
+			case t.isPunct(")") && depth > 0:
+				depth--
+			case t.isPunct("."):
+				// Between the schema and the name of the type.
+			case depth == 0 && t.kind != tokWord && !(t.kind == tokIdent && t.quote != '`'):
+				return nil
+			}
+			_, _ = p.lex.next()
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package sqldumploader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseDump returns the statements of a dump, a CREATE TABLE as "create t(col TYPE, ...)"
+// and an INSERT as "insert t: v1|v2, ..." or "insert t(a, b): v1|v2, ..." with its rows.
+// NULLs are written as NULL and hex and bit literals as x:4D and b:101.
+func parseDump(t *testing.T, dump string) []string {
+	t.Helper()
+	stmts, err := tryParseDump(dump)
+	if err != nil {
+		t.Fatalf("unable to parse the dump: %v", err)
+	}
+	return stmts
+}
+
+func tryParseDump(dump string) ([]string, error) {
+	var stmts []string
+	p := newDumpParser(strings.NewReader(dump))
+	for {
+		stmt, err := p.next()
+		if errors.Is(err, io.EOF) {
+			return stmts, nil
+		}
+		if err != nil {
+			return stmts, err
+		}
+		if stmt.kind == stmtCreate {
+			var cols []string
+			for _, col := range stmt.cols {
+				cols = append(cols, col.name+" "+col.pgType)
+			}
+			stmts = append(stmts, fmt.Sprintf("create %s(%s)", stmt.table, strings.Join(cols, ", ")))
+			continue
+		}
+		var rows []string
+		err = p.rows(func(values []value) error {
+			var vals []string
+			for _, v := range values {
+				switch v.kind {
+				case valNull:
+					vals = append(vals, "NULL")
+				case valHex:
+					vals = append(vals, "x:"+v.text)
+				case valBits:
+					vals = append(vals, "b:"+v.text)
+				default:
+					vals = append(vals, v.text)
+				}
+			}
+			rows = append(rows, strings.Join(vals, "|"))
+			return nil
+		})
+		if err != nil {
+			return stmts, fmt.Errorf("unable to read the rows of %s: %w", stmt.table, err)
+		}
+		table := stmt.table
+		if len(stmt.insertCols) > 0 {
+			table += "(" + strings.Join(stmt.insertCols, ", ") + ")"
+		}
+		stmts = append(stmts, fmt.Sprintf("insert %s: %s", table, strings.Join(rows, ", ")))
+	}
+}
+
+// A pg_dump --inserts dump with a plpgsql function, whose body has statements of its own.
+const pgDumpWithFunction = `--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SET standard_conforming_strings = on;
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE FUNCTION public.log_order() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    INSERT INTO public.audit VALUES (NEW.id, 'it''s new; really');
+    RETURN NEW;
+END;
+$$;
+
+CREATE FUNCTION public.add(a integer, b integer) RETURNS integer
+    LANGUAGE sql
+    AS $body$ SELECT $1 + $2; $body$;
+
+CREATE TABLE public.orders (
+    id integer NOT NULL,
+    key text,
+    index integer,
+    note text DEFAULT 'a;b'::text
+);
+
+INSERT INTO public.orders VALUES (1, 'k1', 10, 'x$$y');
+INSERT INTO public.orders VALUES (2, 'k\2', NULL, $$dollar 'quoted'$$);
+`
+
+func TestParseDumpWithFunction(t *testing.T) {
+	got := parseDump(t, pgDumpWithFunction)
+	want := []string{
+		"create orders(id INT4, key TEXT, index INT4, note TEXT)",
+		"insert orders: 1|k1|10|x$$y",
+		`insert orders: 2|k\2|NULL|dollar 'quoted'`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLexDollarQuoted(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty tag", "$$a;b$$;", []string{"a;b", "end of statement"}},
+		{"named tag", "$fn$ x $$ y $fn$", []string{" x $$ y "}},
+		{"tag with digits", "$t1$v$t1$", []string{"v"}},
+		{"positional parameter", "$1 + $2", []string{`"$1"`, `"+"`, `"$2"`}},
+		{"empty string", "$$$$", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLexer(strings.NewReader(tt.input))
+			var got []string
+			for {
+				tok, err := l.next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tok.kind == tokEOF {
+					break
+				}
+				if tok.kind == tokString {
+					got = append(got, tok.text)
+				} else {
+					got = append(got, tok.String())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexDollarQuotedUnterminated(t *testing.T) {
+	l := newLexer(strings.NewReader("$body$ never closed"))
+	if _, err := l.next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestParseColumnConstraints(t *testing.T) {
+	tests := []struct {
+		def  string
+		want string
+	}{
+		// Columns named like constraint words.
+		{"key text", "key TEXT"},
+		{"key varchar(32)", "key TEXT"},
+		{"index int", "index INT4"},
+		{"check boolean", "check BOOLEAN"},
+		{"period date", "period DATE"},
+		{"exclude smallint", "exclude INT2"},
+		// Keys and constraints.
+		{"PRIMARY KEY (id)", ""},
+		{"UNIQUE (email)", ""},
+		{"UNIQUE KEY `email` (`email`)", ""},
+		{"KEY `idx_name` (`name`)", ""},
+		{"KEY `idx_name` USING BTREE (`name`)", ""},
+		{"INDEX (name)", ""},
+		{"FULLTEXT KEY `ft` (`body`)", ""},
+		{"CONSTRAINT orders_pkey PRIMARY KEY (id)", ""},
+		{"CONSTRAINT `fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)", ""},
+		{"FOREIGN KEY (user_id) REFERENCES users (id)", ""},
+		{"CHECK (price > 0)", ""},
+		{"EXCLUDE USING gist (c WITH &&)", ""},
+		{"PERIOD FOR valid (start_at, end_at)", ""},
+		{"LIKE other INCLUDING ALL", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.def, func(t *testing.T) {
+			p := newDumpParser(strings.NewReader(tt.def + ")"))
+			def, _, err := p.columnDef()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if col, ok := parseColumn(def); ok {
+				got = col.name + " " + col.pgType
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDump(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		want []string
+	}{
+		{
+			name: "mysql table",
+			dump: "CREATE TABLE IF NOT EXISTS `shop`.`users` (\n" +
+				"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+				"  `email` varchar(255) COLLATE utf8mb4_bin DEFAULT NULL,\n" +
+				"  `price` decimal(10,2) DEFAULT '0.00',\n" +
+				"  `created` datetime DEFAULT CURRENT_TIMESTAMP,\n" +
+				"  `flags` set('a','b') DEFAULT NULL,\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  UNIQUE KEY `email` (`email`),\n" +
+				"  KEY `created` (`created`)\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;",
+			want: []string{"create users(id INT8, email TEXT, price NUMERIC(10,2), created TIMESTAMP, flags TEXT)"},
+		},
+		{
+			name: "mysql comments and session settings",
+			dump: "-- MySQL dump 10.13\n" +
+				"/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n" +
+				"# hash comment\n" +
+				"/* block; comment */\n" +
+				"LOCK TABLES `t` WRITE;\n" +
+				"/*!40000 ALTER TABLE `t` DISABLE KEYS */;\n" +
+				"INSERT INTO `t` VALUES (1,'a'),(2,'b');\n" +
+				"UNLOCK TABLES;",
+			want: []string{"insert t: 1|a, 2|b"},
+		},
+		{
+			name: "mysql backslash escapes",
+			dump: `INSERT INTO t VALUES ('it\'s','a\nb','c\\d','50\%','tab\there','nul\0','it''s');`,
+			want: []string{"insert t: it's|a\nb|c\\d|50\\%|tab\there|nul\x00|it's"},
+		},
+		{
+			name: "standard conforming strings",
+			dump: "SET standard_conforming_strings = on;\n" +
+				`INSERT INTO public.t VALUES ('a\b', E'c\nd', 'it''s');`,
+			want: []string{"insert t: a\\b|c\nd|it's"},
+		},
+		{
+			name: "column list",
+			dump: `INSERT INTO "public"."t" (a, "B c", ` + "`d`" + `) VALUES (1, 2, 3);`,
+			want: []string{"insert t(a, B c, d): 1|2|3"},
+		},
+		{
+			name: "literals",
+			dump: `INSERT INTO t VALUES (NULL, true, FALSE, -1, +2.5, .5, 1e3, X'4D79', 0x4D79, b'101', ` +
+				`_binary 'bin', _utf8mb4'utf', N'nat', "dq");`,
+			want: []string{"insert t: NULL|true|false|-1|2.5|.5|1e3|x:4D79|x:4D79|b:101|bin|utf|nat|dq"},
+		},
+		{
+			name: "casts",
+			dump: `INSERT INTO t VALUES ('2024-01-02'::date, 1.5::numeric(10,2), 'x'::character varying, '{1}'::integer[], ` +
+				`'a'::"public"."mood", 'b'::public.mood);`,
+			want: []string{"insert t: 2024-01-02|1.5|x|{1}|a|b"},
+		},
+		{
+			name: "insert modifiers and upserts",
+			dump: "INSERT IGNORE INTO shop.t VALUES (1);\n" +
+				"REPLACE INTO t VALUES (2);\n" +
+				"INSERT INTO t VALUES (3) ON DUPLICATE KEY UPDATE a=VALUES(a);\n" +
+				"INSERT INTO t VALUES (4) ON CONFLICT DO NOTHING;",
+			want: []string{"insert t: 1", "insert t: 2", "insert t: 3", "insert t: 4"},
+		},
+		{
+			name: "delimiter",
+			dump: "DELIMITER ;;\n" +
+				"CREATE TRIGGER trg BEFORE INSERT ON t FOR EACH ROW BEGIN INSERT INTO log VALUES (1); END ;;\n" +
+				"DELIMITER ;\n" +
+				"INSERT INTO t VALUES (5);",
+			want: []string{"insert t: 5"},
+		},
+		{
+			name: "other statements skipped",
+			dump: "CREATE INDEX t_idx ON t (a);\n" +
+				"CREATE VIEW v AS SELECT 'x;y' AS a;\n" +
+				"CREATE TABLE t2 AS SELECT * FROM t;\n" +
+				"CREATE TEMPORARY TABLE tmp (a int);\n" +
+				"ALTER TABLE ONLY t ADD CONSTRAINT t_pkey PRIMARY KEY (a);\n" +
+				"SELECT pg_catalog.setval('t_id_seq', 42, true);",
+			want: []string{"create tmp(a INT4)"},
+		},
+		{
+			name: "postgres table",
+			dump: "CREATE TABLE public.events (\n" +
+				"    id bigint NOT NULL,\n" +
+				"    at timestamp with time zone DEFAULT now(),\n" +
+				"    amount double precision,\n" +
+				"    name character varying(64),\n" +
+				"    tags text[],\n" +
+				"    payload jsonb,\n" +
+				"    CONSTRAINT events_amount_check CHECK ((amount > (0)::double precision))\n" +
+				");",
+			want: []string{"create events(id INT8, at TIMESTAMPTZ, amount DOUBLE PRECISION, name TEXT, tags TEXT[], payload JSONB)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDump(t, tt.dump); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDumpErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{"copy from stdin", "COPY public.t (a) FROM stdin;\n1\n\\.\n"},
+		{"insert select", "INSERT INTO t SELECT * FROM u;"},
+		{"function value", "INSERT INTO t VALUES (now());"},
+		{"unterminated string", "INSERT INTO t VALUES ('abc);"},
+		{"unterminated comment", "/* never closed"},
+		{"sign without a number", "INSERT INTO t VALUES (-'a');"},
+		{"missing comma", "INSERT INTO t VALUES (1 2);"},
+		{"empty delimiter", "DELIMITER \nINSERT INTO t VALUES (1);"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tryParseDump(tt.dump); err == nil {
+				t.Errorf("got %q, want an error", got)
+			}
+		})
+	}
+}
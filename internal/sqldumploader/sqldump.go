@@ -0,0 +1,236 @@
+package sqldumploader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+)
+
+// A table of a dump that gets loaded into its own PostgreSQL table.
+type table struct {
+	// Name in the dump.
+	source string
+	name   string
+	cols   []column
+	rows   int64
+}
+
+type SQLDumpLoader struct {
+	maxConcurrency int
+
+	typeSetting string
+
+	filesList []string
+
+	// Tables of files loaded in parallel must not clash.
+	mu      sync.Mutex
+	claimed map[string]string
+
+	db *dbv2.DB
+}
+
+func New(files []string, db *dbv2.DB, concurrency int, t string) *SQLDumpLoader {
+	return &SQLDumpLoader{
+		maxConcurrency: concurrency,
+		typeSetting:    t,
+		claimed:        map[string]string{},
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (s *SQLDumpLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, total, failed int64
+	start := time.Now()
+
+	err := shared.RunInParallel(s.maxConcurrency, s.filesList, func(file string) error {
+		tables, err := s.load(ctx, file)
+		if err != nil {
+			// The rest of the dump isn't read, so none of its tables are complete.
+			if len(tables) == 0 {
+				tables = []*table{{name: shared.GetTableName(file)}}
+			}
+			for _, t := range tables {
+				_ = s.db.DeleteTable(t.name)
+				printError(file, t.source, t.name, err)
+			}
+			atomic.AddInt64(&total, int64(len(tables)))
+			atomic.AddInt64(&failed, int64(len(tables)))
+			return err
+		}
+
+		for _, t := range tables {
+			atomic.AddInt64(&totalRowsInserted, t.rows)
+			fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s source_table=%q table=%s\n",
+				shared.FormatNumber(t.rows), shared.GetFileSize(file), file, t.source, t.name)
+		}
+		atomic.AddInt64(&total, int64(len(tables)))
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"SQL", total, total-failed, failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+// load reads the dump in a single pass. Tables are created as their CREATE TABLE statements
+// come, and the rows of consecutive INSERTs into a table go through the same COPY.
+// It returns the tables created so far, even when it fails.
+func (s *SQLDumpLoader) load(ctx context.Context, file string) ([]*table, error) {
+	r, err := reader.NewFileGzipReaderContext(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		tables []*table
+		byName = map[string]*table{}
+		p      = newDumpParser(r)
+	)
+	stmt, err := p.next()
+	for err == nil {
+		name := shared.GetObjectTableName(stmt.table)
+		t, exists := byName[name]
+		if !exists {
+			if t, err = s.createTable(file, name, stmt); err != nil {
+				return tables, err
+			}
+			byName[name] = t
+			tables = append(tables, t)
+		}
+
+		if stmt.kind == stmtCreate {
+			stmt, err = p.next()
+			continue
+		}
+		stmt, err = s.copyInserts(ctx, p, stmt, t)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return tables, err
+}
+
+// createTable creates the table of a CREATE TABLE, or of an INSERT into a table the dump
+// doesn't create, with TEXT columns named after the INSERT's column list.
+func (s *SQLDumpLoader) createTable(file, name string, stmt *statement) (*table, error) {
+	t := &table{source: stmt.table, name: name, cols: stmt.cols}
+	if stmt.kind == stmtInsert {
+		if len(stmt.insertCols) == 0 {
+			return nil, fmt.Errorf("no CREATE TABLE for %q before its INSERTs, and they don't list their columns", stmt.table)
+		}
+		for _, col := range stmt.insertCols {
+			t.cols = append(t.cols, column{name: col, pgType: dbv2.Text})
+		}
+	}
+
+	s.mu.Lock()
+	other, claimed := s.claimed[name]
+	if !claimed {
+		s.claimed[name] = file
+	}
+	s.mu.Unlock()
+	if claimed {
+		return nil, fmt.Errorf("table %q is also loaded from %s into %s", stmt.table, other, name)
+	}
+
+	colsTypes := make([]string, len(t.cols))
+	for i, col := range t.cols {
+		if s.typeSetting == shared.AllText {
+			t.cols[i].pgType = dbv2.Text
+		}
+		colsTypes[i] = strconv.Quote(col.name) + " " + t.cols[i].pgType
+	}
+	// Ensure the table exists or create it if necessary.
+	if err := s.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// copyInserts loads the rows of the INSERT, and of the INSERTs right after it into the same
+// columns, with a single COPY. It returns the statement that follows them.
+func (s *SQLDumpLoader) copyInserts(ctx context.Context, p *dumpParser, stmt *statement, t *table) (*statement, error) {
+	cols, err := t.insertColumns(stmt.insertCols)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.name
+	}
+
+	var (
+		next    *statement
+		nextErr error
+	)
+	rowsInserted, err := csv2.LoadTextStream(ctx, t.name, s.db, names, func(w io.Writer) error {
+		bw := bufio.NewWriterSize(w, 64*1024)
+		row := make([]string, len(cols))
+		for {
+			err := p.rows(func(values []value) error {
+				if len(values) != len(cols) {
+					return fmt.Errorf("INSERT into %q has a row with %d values for %d columns", t.source, len(values), len(cols))
+				}
+				for i, v := range values {
+					var err error
+					if row[i], err = encodeValue(v, cols[i].pgType, p.lex.backslash); err != nil {
+						return fmt.Errorf("column %q of %q: %w", cols[i].name, t.source, err)
+					}
+				}
+				bw.WriteString(strings.Join(row, "\t"))
+				return bw.WriteByte('\n')
+			})
+			if err != nil {
+				return err
+			}
+
+			next, nextErr = p.next()
+			if nextErr != nil || !stmt.sameInsert(next) {
+				return bw.Flush()
+			}
+		}
+	})
+	t.rows += rowsInserted
+	if err != nil {
+		return nil, err
+	}
+	return next, nextErr
+}
+
+// insertColumns returns the table columns an INSERT lists, or all of them when it lists none.
+func (t *table) insertColumns(names []string) ([]column, error) {
+	if len(names) == 0 {
+		return t.cols, nil
+	}
+	cols := make([]column, len(names))
+	for i, name := range names {
+		found := false
+		for _, col := range t.cols {
+			if strings.EqualFold(col.name, name) {
+				cols[i], found = col, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("INSERT into %q has column %q, which its CREATE TABLE doesn't", t.source, name)
+		}
+	}
+	return cols, nil
+}
+
+func printError(f, table, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="SQL" msg="unable to load" file=%q source_table=%q name=%q error=%q`+"\n", f, table, name, err.Error())
+}
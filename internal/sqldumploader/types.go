@@ -0,0 +1,243 @@
+package sqldumploader
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+)
+
+type column struct {
+	name   string
+	pgType string
+}
+
+// Words that start a named constraint, after CONSTRAINT name.
+var constraintWords = []string{"primary", "unique", "foreign", "check", "exclude"}
+
+// Words that can follow the first word of a type, like DOUBLE PRECISION or CHARACTER VARYING.
+var typeWords = []string{"precision", "varying", "character", "char", "varchar", "large", "object"}
+
+// parseColumn returns the column of a column definition, skipping keys and constraints.
+func parseColumn(def []token) (column, bool) {
+	if len(def) < 2 {
+		return column{}, false
+	}
+	if isConstraint(def) {
+		return column{}, false
+	}
+	if def[0].kind != tokWord && def[0].kind != tokIdent {
+		return column{}, false
+	}
+	return column{name: def[0].text, pgType: translateType(def[1:])}, true
+}
+
+// isConstraint reports whether a definition is a key, index or constraint, going by its shape
+// rather than its first word, since pg_dump doesn't quote columns named key or index:
+// PRIMARY KEY (, UNIQUE (, CONSTRAINT name CHECK, KEY name ( and INDEX name ( of MySQL,
+// FOREIGN KEY, CHECK ( and the like.
+func isConstraint(def []token) bool {
+	if def[0].kind != tokWord {
+		return false
+	}
+	at := func(i int) token {
+		if i < len(def) {
+			return def[i]
+		}
+		return token{kind: tokEOF}
+	}
+	isName := func(t token) bool {
+		return t.kind == tokWord || t.kind == tokIdent
+	}
+	// The column list of an index, like (`name`), as opposed to the arguments of a type,
+	// like varchar(10).
+	indexColumns := func(i int) bool {
+		return at(i).isPunct("(") && isName(at(i+1))
+	}
+
+	second := at(1)
+	switch strings.ToLower(def[0].text) {
+	case "primary", "foreign":
+		return second.isWord("key")
+	case "unique", "fulltext", "spatial":
+		return second.isPunct("(") || second.isWord("key") || second.isWord("index") || isName(second) && indexColumns(2)
+	case "key", "index":
+		return indexColumns(1) || isName(second) && (indexColumns(2) || at(2).isWord("using"))
+	case "constraint":
+		isKind := func(t token) bool {
+			return t.kind == tokWord && slices.Contains(constraintWords, strings.ToLower(t.text))
+		}
+		return isKind(second) || isName(second) && isKind(at(2))
+	case "check":
+		return second.isPunct("(")
+	case "exclude":
+		return second.isPunct("(") || second.isWord("using")
+	case "period":
+		return second.isWord("for")
+	case "like":
+		return isName(second) && (len(def) == 2 || at(2).isWord("including") || at(2).isWord("excluding"))
+	}
+	return false
+}
+
+// translateType maps the MySQL or standard SQL type at the start of a column definition
+// to a PostgreSQL type. Unknown types, including ENUM and SET, become TEXT.
+func translateType(def []token) string {
+	if def[0].kind != tokWord {
+		return dbv2.Text
+	}
+	name := strings.ToLower(def[0].text)
+	i := 1
+	for i < len(def) && def[i].kind == tokWord && slices.Contains(typeWords, strings.ToLower(def[i].text)) {
+		name += " " + strings.ToLower(def[i].text)
+		i++
+	}
+
+	var args []string
+	if i < len(def) && def[i].isPunct("(") {
+		for i++; i < len(def) && !def[i].isPunct(")"); i++ {
+			if def[i].kind == tokNumber {
+				args = append(args, def[i].text)
+			}
+		}
+		i++
+	}
+
+	var unsigned, withTZ, array bool
+	for ; i < len(def); i++ {
+		switch t := def[i]; {
+		case t.isWord("unsigned"):
+			unsigned = true
+		case t.isWord("with"):
+			withTZ = true
+		case t.isWord("signed"), t.isWord("zerofill"), t.isWord("without"), t.isWord("time"), t.isWord("zone"):
+		case t.kind == tokIdent && t.quote == '[' && t.text == "":
+			// PostgreSQL arrays, like INTEGER[].
+			array = true
+		default:
+			i = len(def)
+		}
+	}
+
+	pgType := baseType(name, args, unsigned, withTZ)
+	if array {
+		pgType += "[]"
+	}
+	return pgType
+}
+
+func baseType(name string, args []string, unsigned, withTZ bool) string {
+	switch name {
+	case "tinyint", "smallint", "int2", "year", "smallserial", "serial2":
+		if unsigned && name == "smallint" {
+			return dbv2.Integer
+		}
+		return dbv2.SmallInt
+	case "mediumint", "int", "integer", "int4", "serial", "serial4":
+		if unsigned && name != "mediumint" {
+			return dbv2.BigInt
+		}
+		return dbv2.Integer
+	case "bigint", "int8", "bigserial", "serial8":
+		if unsigned {
+			return dbv2.NumericOf(20, 0)
+		}
+		return dbv2.BigInt
+	case "decimal", "numeric", "dec", "fixed":
+		if len(args) == 0 {
+			return dbv2.Numeric
+		}
+		precision, scale := args[0], "0"
+		if len(args) > 1 {
+			scale = args[1]
+		}
+		return fmt.Sprintf("%s(%s,%s)", dbv2.Numeric, precision, scale)
+	case "float", "float4":
+		// FLOAT(p) with more than 24 bits of precision is a double.
+		if len(args) == 1 {
+			if p, _ := strconv.Atoi(args[0]); p > 24 {
+				return dbv2.Double
+			}
+		}
+		return dbv2.Real
+	case "double", "double precision", "float8", "real":
+		// REAL is a double in MySQL.
+		return dbv2.Double
+	case "bool", "boolean":
+		return dbv2.Boolean
+	case "bit":
+		if len(args) == 0 || args[0] == "1" {
+			return dbv2.Boolean
+		}
+		return dbv2.BigInt
+	case "date":
+		return dbv2.Date
+	case "datetime", "timestamp":
+		if withTZ {
+			return dbv2.TimestampTZ
+		}
+		return dbv2.Timestamp
+	case "timestamptz":
+		return dbv2.TimestampTZ
+	case "time":
+		if withTZ {
+			return "TIMETZ"
+		}
+		return dbv2.Time
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bytea", "binary varying", "binary large object":
+		return dbv2.Bytea
+	case "json":
+		return dbv2.Jsonb
+	case "jsonb", "uuid", "inet", "cidr", "macaddr", "interval", "money":
+		return strings.ToUpper(name)
+	}
+	return dbv2.Text
+}
+
+// encodeValue encodes a value of an INSERT in the text format of COPY for a column of pgType.
+// binaryStrings tells whether strings for BYTEA columns hold the raw bytes, as in MySQL
+// dumps, rather than PostgreSQL's bytea input.
+func encodeValue(v value, pgType string, binaryStrings bool) (string, error) {
+	switch v.kind {
+	case valNull:
+		return csvutils.CopyTextNull, nil
+	case valHex:
+		b, err := hex.DecodeString(v.text)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex literal %q: %v", v.text, err)
+		}
+		if pgType == dbv2.Bytea {
+			return csvutils.CopyTextEscaper.Replace(`\x` + v.text), nil
+		}
+		return csvutils.CopyTextEscaper.Replace(string(b)), nil
+	case valBits:
+		n, err := strconv.ParseUint(v.text, 2, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid bit literal %q: %v", v.text, err)
+		}
+		return strconv.FormatUint(n, 10), nil
+	case valBool:
+		if pgType == dbv2.Boolean {
+			return v.text, nil
+		}
+		if v.text == "true" {
+			return "1", nil
+		}
+		return "0", nil
+	case valNumber:
+		return v.text, nil
+	}
+
+	switch {
+	case pgType == dbv2.Bytea && binaryStrings:
+		return csvutils.CopyTextEscaper.Replace(`\x` + hex.EncodeToString([]byte(v.text))), nil
+	case (pgType == dbv2.Date || pgType == dbv2.Timestamp || pgType == dbv2.TimestampTZ) && strings.HasPrefix(v.text, "0000-00-00"):
+		// MySQL's zero dates have no PostgreSQL equivalent.
+		return csvutils.CopyTextNull, nil
+	}
+	return csvutils.CopyTextEscaper.Replace(v.text), nil
+}
@@ -0,0 +1,420 @@
+package sqliteloader
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	builterr "errors"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+
+	_ "modernc.org/sqlite"
+)
+
+type column struct {
+	name   string
+	pgType string
+}
+
+// A SQLite table that gets loaded into its own PostgreSQL table.
+type sourceTable struct {
+	file string
+	name string
+	db   *sql.DB
+}
+
+type SQLiteLoader struct {
+	maxConcurrency int
+	lookUpSize     int
+
+	typeSetting string
+	// SQLite tables to load; all tables are loaded when empty.
+	tables []string
+
+	filesList []string
+
+	db *dbv2.DB
+}
+
+func New(files []string, db *dbv2.DB, concurrency, lookUp int, t string, tables []string) *SQLiteLoader {
+	return &SQLiteLoader{
+		maxConcurrency: concurrency,
+		lookUpSize:     lookUp,
+		typeSetting:    t,
+		tables:         tables,
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (s *SQLiteLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, failed int64
+	start := time.Now()
+
+	sources, names, errs := s.findTables(ctx)
+	failed = int64(len(errs))
+	total := len(names) + len(errs)
+	defer func() {
+		for _, src := range sources {
+			_ = src.db.Close()
+		}
+	}()
+
+	// Tables of the same file are loaded in parallel too, so big databases don't run one table at a time.
+	err := shared.RunInParallel(s.maxConcurrency, names, func(name string) error {
+		var err error
+
+		src := sources[name]
+		defer func() {
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = s.db.DeleteTable(name)
+			}
+		}()
+
+		rowsInserted, err := s.load(ctx, src, name)
+		if err != nil {
+			printError(src.file, src.name, name, err)
+			return err
+		}
+		atomic.AddInt64(&totalRowsInserted, rowsInserted)
+		fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s source_table=%q table=%s\n",
+			shared.FormatNumber(rowsInserted), shared.GetFileSize(src.file), src.file, src.name, name)
+		return nil
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"SQLITE", total, total-int(failed), failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, builterr.Join(append(errs, err)...)
+}
+
+// findTables lists the tables to load from every file, keyed by their PostgreSQL table name.
+func (s *SQLiteLoader) findTables(ctx context.Context) (map[string]sourceTable, []string, []error) {
+	var (
+		errs  []error
+		names []string
+
+		sources = map[string]sourceTable{}
+		found   = map[string]bool{}
+	)
+	for _, file := range s.filesList {
+		db, tables, err := listTables(ctx, file)
+		if err != nil {
+			printError(file, "", shared.GetTableName(file), err)
+			errs = append(errs, err)
+			continue
+		}
+
+		used := false
+		for _, table := range tables {
+			idx := slices.IndexFunc(s.tables, func(t string) bool { return strings.EqualFold(t, table) })
+			if len(s.tables) > 0 && idx == -1 {
+				continue
+			}
+			if idx != -1 {
+				found[s.tables[idx]] = true
+			}
+
+			name := shared.GetObjectTableName(table)
+			if other, exists := sources[name]; exists {
+				err := fmt.Errorf("table %q is also loaded from %s into %s", table, other.file, name)
+				printError(file, table, name, err)
+				errs = append(errs, err)
+				continue
+			}
+			sources[name] = sourceTable{file: file, name: table, db: db}
+			names = append(names, name)
+			used = true
+		}
+		if !used {
+			_ = db.Close()
+		}
+	}
+
+	for _, table := range s.tables {
+		if !found[table] {
+			err := fmt.Errorf("table %q not found in any of the given files", table)
+			printError("", table, shared.GetObjectTableName(table), err)
+			errs = append(errs, err)
+		}
+	}
+	return sources, names, errs
+}
+
+func listTables(ctx context.Context, file string) (*sql.DB, []string, error) {
+	// SQLite creates a new database when the file doesn't exist.
+	if _, err := os.Stat(file); err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+file+"?mode=ro")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY name`)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		tables = append(tables, table)
+	}
+	if err = rows.Err(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, tables, nil
+}
+
+func (s *SQLiteLoader) load(ctx context.Context, src sourceTable, name string) (int64, error) {
+	cols, err := s.columns(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+
+	colsTypes := make([]string, len(cols))
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		colsTypes[i] = strconv.Quote(col.name) + " " + col.pgType
+		names[i] = col.name
+	}
+	// Ensure the table exists or create it if necessary.
+	if err = s.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return 0, err
+	}
+
+	return csv2.LoadTextStream(ctx, name, s.db, names, func(w io.Writer) error {
+		return convertTableToText(ctx, w, src, cols)
+	})
+}
+
+func (s *SQLiteLoader) columns(ctx context.Context, src sourceTable) ([]column, error) {
+	rows, err := src.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(src.name)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []column
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, declared   string
+			defaultValue     any
+		)
+		if err = rows.Scan(&cid, &name, &declared, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		cols = append(cols, column{name: name, pgType: declared})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("table %q has no columns", src.name)
+	}
+
+	for i, col := range cols {
+		switch {
+		case s.typeSetting == shared.AllText:
+			cols[i].pgType = dbv2.Text
+		case col.pgType == "":
+			// Columns declared without a type can hold anything, so look at the values instead.
+			if cols[i].pgType, err = s.sampleType(ctx, src, col.name); err != nil {
+				return nil, err
+			}
+		default:
+			cols[i].pgType = declaredType(col.pgType)
+		}
+	}
+	return cols, nil
+}
+
+// declaredType follows SQLite's type affinity rules (https://www.sqlite.org/datatype3.html),
+// using a closer PostgreSQL type for the common declared types that fall under NUMERIC affinity.
+func declaredType(declared string) string {
+	t := strings.ToUpper(declared)
+	switch {
+	case strings.Contains(t, "INT"):
+		return dbv2.BigInt
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return dbv2.Text
+	case strings.Contains(t, "BLOB"):
+		return dbv2.Bytea
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return dbv2.Double
+	case strings.Contains(t, "BOOL"):
+		return dbv2.Boolean
+	case strings.Contains(t, "DATETIME"), strings.Contains(t, "TIMESTAMP"):
+		return dbv2.Timestamp
+	case strings.Contains(t, "DATE"):
+		return dbv2.Date
+	case strings.Contains(t, "TIME"):
+		return dbv2.Time
+	case strings.Contains(t, "UUID"), strings.Contains(t, "JSON"):
+		// Usually stored as text, which NUMERIC can't hold.
+		return dbv2.Text
+	}
+	return dbv2.Numeric
+}
+
+// sampleType picks a type from the storage classes of the first lookUp values of the column.
+func (s *SQLiteLoader) sampleType(ctx context.Context, src sourceTable, col string) (string, error) {
+	query := fmt.Sprintf(`SELECT DISTINCT typeof(%[1]s) FROM (SELECT %[1]s FROM %[2]s LIMIT %[3]d)`,
+		quoteIdent(col), quoteIdent(src.name), s.lookUpSize)
+	rows, err := src.db.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	classes := map[string]bool{}
+	for rows.Next() {
+		var class string
+		if err = rows.Scan(&class); err != nil {
+			return "", err
+		}
+		if class != "null" {
+			classes[class] = true
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(classes) == 1 && classes["integer"]:
+		return dbv2.BigInt, nil
+	case len(classes) == 1 && classes["real"], len(classes) == 2 && classes["integer"] && classes["real"]:
+		return dbv2.Double, nil
+	case len(classes) == 1 && classes["blob"]:
+		return dbv2.Bytea, nil
+	}
+	return dbv2.Text, nil
+}
+
+// convertTableToText writes the rows in the text format of COPY, with \N only for NULLs, so
+// empty strings are loaded as empty strings.
+func convertTableToText(ctx context.Context, w io.Writer, src sourceTable, cols []column) error {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteIdent(col.name)
+	}
+	rows, err := src.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdent(src.name)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	bw := bufio.NewWriterSize(w, 64*1024)
+	values := make([]any, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, col := range cols {
+			if i > 0 {
+				bw.WriteByte('\t')
+			}
+			if isNull(values[i], col.pgType) {
+				bw.WriteString(csvutils.CopyTextNull)
+				continue
+			}
+			csvutils.CopyTextEscaper.WriteString(bw, toString(values[i], col.pgType))
+		}
+		if err = bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// isNull reports whether the value is loaded as a null: NULLs, and empty strings in the
+// columns whose PostgreSQL type has no empty value, which SQLite's loose typing lets through.
+func isNull(val any, pgType string) bool {
+	if val == nil {
+		return true
+	}
+	empty := val == ""
+	if b, ok := val.([]byte); ok {
+		empty = len(b) == 0
+	}
+	return empty && pgType != dbv2.Text && pgType != dbv2.Bytea
+}
+
+func toString(val any, pgType string) string {
+	temporal := pgType == dbv2.Date || pgType == dbv2.Timestamp
+	switch t := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case int64:
+		// SQLite has no date type, so dates are also stored as unix seconds ...
+		if temporal {
+			return formatTime(time.Unix(t, 0).UTC(), pgType)
+		}
+		return strconv.FormatInt(t, 10)
+	case float64:
+		// ... or as julian day numbers.
+		if temporal {
+			unix := (t - 2440587.5) * 86400
+			return formatTime(time.Unix(0, int64(unix*float64(time.Second))).UTC(), pgType)
+		}
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	case []byte:
+		if pgType != dbv2.Bytea && utf8.Valid(t) {
+			return string(t)
+		}
+		return `\x` + hex.EncodeToString(t)
+	case time.Time:
+		return formatTime(t, pgType)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return fmt.Sprint(val)
+}
+
+func formatTime(t time.Time, pgType string) string {
+	if pgType == dbv2.Date {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format("2006-01-02 15:04:05.999999999")
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func printError(f, table, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="SQLITE" msg="unable to load" file=%q source_table=%q name=%q error=%q`+"\n", f, table, name, err.Error())
+}
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/pkg/errors"
+)
+
+// Suffix of the marker files that tell a file next to them is complete, like orders.csv.done.
+const doneSuffix = ".done"
+
+// A file in the watched directory that isn't loaded yet.
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	// When the size or modification time last changed.
+	since time.Time
+}
+
+type watcher struct {
+	c   *CommandInfo
+	dir string
+
+	interval time.Duration
+	settle   time.Duration
+	// Load only the files that have a .done marker.
+	doneOnly bool
+
+	processedDir string
+	failedDir    string
+
+	pending map[string]*pendingFile
+	// Files that couldn't be moved out after loading, which aren't loaded again.
+	mu    sync.Mutex
+	stuck map[string]bool
+}
+
+// Watch loads the files that land in dir until ctx is done. A file is loaded once its
+// .done marker shows up, or once its size stays the same for the settle time, and is then
+// moved into the processed directory, or into the failed one along with a <file>.error note.
+func (c *CommandInfo) Watch(ctx context.Context, dir string) error {
+	if err := c.db.EnsureSchema(); err != nil {
+		return err
+	}
+	w, err := c.newWatcher(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("msg=\"watching for files\" dir=%s processed_dir=%s failed_dir=%s\n", dir, w.processedDir, w.failedDir)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *CommandInfo) newWatcher(dir string) (*watcher, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	w := &watcher{
+		c:            c,
+		dir:          dir,
+		doneOnly:     c.flagsMapB[DoneOnly],
+		processedDir: c.flagsMapS[ProcessedDir],
+		failedDir:    c.flagsMapS[FailedDir],
+		pending:      map[string]*pendingFile{},
+		stuck:        map[string]bool{},
+	}
+	for flag, d := range map[string]*time.Duration{PollInterval: &w.interval, SettleTime: &w.settle} {
+		if *d, err = time.ParseDuration(c.flagsMapS[flag]); err != nil || *d <= 0 {
+			return nil, fmt.Errorf("invalid duration %q for flag %q", c.flagsMapS[flag], flag)
+		}
+	}
+	for _, d := range []*string{&w.processedDir, &w.failedDir} {
+		if !filepath.IsAbs(*d) {
+			*d = filepath.Join(dir, *d)
+		}
+		if err := os.MkdirAll(*d, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// poll loads the files of the directory that are ready.
+func (w *watcher) poll(ctx context.Context) error {
+	ready, err := w.readyFiles()
+	if err != nil {
+		return err
+	}
+	if len(ready) == 0 {
+		return nil
+	}
+	_ = shared.RunInParallel(concurrentRuns, ready, func(file string) error {
+		// Files of the same name append to the same table, which a failed file must not drop.
+		run := *w.c
+		run.db = w.c.db.ForRun()
+		err := run.RunFormatSpecificLoaders(ctx, run.categorizeFiles([]string{file}))
+		if ctx.Err() != nil {
+			// Stopped while loading; the file is loaded again on the next run.
+			return ctx.Err()
+		}
+		w.finish(file, err)
+		return err
+	})
+	return nil
+}
+
+// readyFiles returns the files that are complete, going by their .done marker or by their
+// size staying the same for the settle time.
+func (w *watcher) readyFiles() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read directory %s", w.dir)
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+
+	var ready []string
+	now := time.Now()
+	seen := map[string]bool{}
+	for _, e := range entries {
+		name := e.Name()
+		file := filepath.Join(w.dir, name)
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, doneSuffix) ||
+			w.stuck[file] || !w.c.isLoadable(file) {
+			continue
+		}
+		seen[file] = true
+
+		if names[name+doneSuffix] {
+			ready = append(ready, file)
+			continue
+		}
+		if w.doneOnly {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// Moved or removed since the directory was read.
+			continue
+		}
+		p, ok := w.pending[file]
+		if !ok || p.size != info.Size() || !p.modTime.Equal(info.ModTime()) {
+			w.pending[file] = &pendingFile{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(p.since) >= w.settle {
+			ready = append(ready, file)
+		}
+	}
+
+	for file := range w.pending {
+		if !seen[file] {
+			delete(w.pending, file)
+		}
+	}
+	for _, file := range ready {
+		delete(w.pending, file)
+	}
+	return ready, nil
+}
+
+// isLoadable reports whether the file is in one of the formats being loaded. Other files,
+// like uploads still named upload.csv.part, are left alone.
+func (c *CommandInfo) isLoadable(file string) bool {
+	files := c.categorizeFiles([]string{file})
+	for _, f := range formatsToLoad(c.flagsMapS[Format]) {
+		if len(files[f]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// finish moves a loaded file, and its .done marker, into the processed or failed directory.
+func (w *watcher) finish(file string, loadErr error) {
+	dir := w.processedDir
+	if loadErr != nil {
+		dir = w.failedDir
+	}
+	moved, err := moveFile(file, dir)
+	if err != nil {
+		w.mu.Lock()
+		w.stuck[file] = true
+		w.mu.Unlock()
+		fmt.Printf("status=FAILED msg=\"unable to move file\" file=%q dir=%q error=%q\n", file, dir, err.Error())
+		return
+	}
+	if _, err := os.Stat(file + doneSuffix); err == nil {
+		_ = os.Rename(file+doneSuffix, moved+doneSuffix)
+	}
+	if loadErr != nil {
+		_ = os.WriteFile(moved+".error", []byte(loadErr.Error()+"\n"), 0o644)
+	}
+	fmt.Printf("msg=\"moved file\" file=%q to=%q\n", file, moved)
+}
+
+// moveFile moves the file into dir, adding a timestamp to its name when dir already has
+// a file with the same name, like orders.20240601T101500.csv.
+func moveFile(file, dir string) (string, error) {
+	name := filepath.Base(file)
+	dest := filepath.Join(dir, name)
+	if _, err := os.Stat(dest); err == nil {
+		base, ext, _ := strings.Cut(name, ".")
+		if ext != "" {
+			ext = "." + ext
+		}
+		dest = filepath.Join(dir, base+"."+time.Now().Format("20060102T150405")+ext)
+	}
+	return dest, os.Rename(file, dest)
+}
@@ -0,0 +1,457 @@
+package xlsxloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	builterr "errors"
+
+	csv2 "github.com/anvesh9652/pgload/internal/csvloader/v2"
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+	"github.com/xuri/excelize/v2"
+)
+
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+// Built-in number format ids that render a serial number as a date or time.
+var builtInDateFormats = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	45: true, 46: true, 47: true, 50: true, 51: true, 52: true, 53: true, 54: true, 55: true, 56: true,
+	57: true, 58: true,
+}
+
+// Quoted literals, escaped characters and bracketed sections such as [Red] or [$-409]
+// can't tell us whether a custom format is a date.
+var numFmtNoise = regexp.MustCompile(`"[^"]*"|\\.|\[[^\]]*\]`)
+
+type Options struct {
+	// Sheets to load. Every sheet in the workbook is loaded when empty.
+	Sheets []string
+	// Number of rows to skip before the header row.
+	HeaderOffset int
+	// Cell range, like "B2:F100", that is read from every sheet.
+	Range string
+}
+
+type XLSXLoader struct {
+	maxConcurrency int
+	lookUpSize     int
+
+	typeSetting string
+	opts        Options
+
+	filesList []string
+
+	db *dbv2.DB
+}
+
+type sheetRange struct {
+	firstCol, firstRow int
+	// Zero means there is no limit.
+	lastCol, lastRow int
+}
+
+type sheetReader struct {
+	f         *excelize.File
+	sheet     string
+	date1904  bool
+	rng       sheetRange
+	headerRow int
+}
+
+func New(files []string, db *dbv2.DB, concurrency, lookUp int, t string, opts Options) *XLSXLoader {
+	return &XLSXLoader{
+		maxConcurrency: concurrency,
+		typeSetting:    t,
+		lookUpSize:     lookUp,
+		opts:           opts,
+		db:             db,
+		filesList:      files,
+	}
+}
+
+func (x *XLSXLoader) Run(ctx context.Context) (string, error) {
+	var totalRowsInserted, total, failed int64
+	start := time.Now()
+
+	rng, err := parseRange(x.opts.Range)
+	if err != nil {
+		return "", err
+	}
+
+	err = shared.RunInParallel(x.maxConcurrency, x.filesList, func(file string) error {
+		f, err := excelize.OpenFile(file)
+		if err != nil {
+			// Counted as one failed load, since the sheets aren't known.
+			atomic.AddInt64(&total, int64(1))
+			atomic.AddInt64(&failed, int64(1))
+			printError(file, "", shared.GetTableName(file), err)
+			return err
+		}
+		defer f.Close()
+
+		sheets, err := x.sheetsToLoad(f)
+		if err != nil {
+			atomic.AddInt64(&total, int64(1))
+			atomic.AddInt64(&failed, int64(1))
+			printError(file, "", shared.GetTableName(file), err)
+			return err
+		}
+
+		var date1904 bool
+		if props, err := f.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+			date1904 = *props.Date1904
+		}
+
+		// A failing sheet shouldn't stop the rest of the workbook from loading.
+		var errs []error
+		for _, sheet := range sheets {
+			atomic.AddInt64(&total, int64(1))
+			name := shared.GetSheetTableName(file, sheet)
+			sr := &sheetReader{
+				f: f, sheet: sheet, date1904: date1904, rng: rng,
+				headerRow: rng.firstRow + x.opts.HeaderOffset,
+			}
+
+			rowsInserted, err := x.loadSheet(ctx, sr, name)
+			if err != nil {
+				atomic.AddInt64(&failed, int64(1))
+				_ = x.db.DeleteTable(name)
+				printError(file, sheet, name, err)
+				errs = append(errs, err)
+				continue
+			}
+			atomic.AddInt64(&totalRowsInserted, rowsInserted)
+			fmt.Printf("status=SUCCESS rows_inserted=%s file_size=%s file=%s sheet=%q table=%s\n",
+				shared.FormatNumber(rowsInserted), shared.GetFileSize(file), file, sheet, name)
+		}
+		return builterr.Join(errs...)
+	})
+
+	msg := fmt.Sprintf(`msg="final load stats" data_format=%q total=%d success=%d failed=%d total_rows_inserted=%s took=%s`,
+		"XLSX", total, total-failed, failed, shared.FormatNumber(totalRowsInserted), time.Since(start))
+	return msg, err
+}
+
+func (x *XLSXLoader) sheetsToLoad(f *excelize.File) ([]string, error) {
+	all := f.GetSheetList()
+	if len(x.opts.Sheets) == 0 {
+		return all, nil
+	}
+	for _, sheet := range x.opts.Sheets {
+		if idx, _ := f.GetSheetIndex(sheet); idx == -1 {
+			return nil, fmt.Errorf("sheet %q doesn't exist, available sheets: %s", sheet, strings.Join(all, ", "))
+		}
+	}
+	return x.opts.Sheets, nil
+}
+
+func (x *XLSXLoader) loadSheet(ctx context.Context, sr *sheetReader, name string) (int64, error) {
+	headers, samples, err := sr.sample(x.lookUpSize)
+	if err != nil {
+		return 0, err
+	}
+
+	types := make([]string, len(headers))
+	colsTypes := make([]string, len(headers))
+	for i, col := range headers {
+		types[i] = dbv2.Text
+		if x.typeSetting != shared.AllText {
+			types[i] = sr.columnType(i, samples)
+		}
+		colsTypes[i] = strconv.Quote(col) + " " + types[i]
+	}
+
+	// Ensure the table exists or create it if necessary.
+	if err = x.db.EnsureTable(name, fmt.Sprintf("(%s)", strings.Join(colsTypes, ", "))); err != nil {
+		return 0, err
+	}
+
+	return csv2.LoadTextStream(ctx, name, x.db, headers, func(w io.Writer) error {
+		bw := bufio.NewWriterSize(w, 64*1024)
+		err := sr.eachRow(func(rowNum int, cells []string) error {
+			if rowNum <= sr.headerRow {
+				return nil
+			}
+			for i, val := range cells {
+				if i > 0 {
+					bw.WriteByte('\t')
+				}
+				if val == "" && (types[i] != dbv2.Text || !sr.holdsString(rowNum, i)) {
+					bw.WriteString(csvutils.CopyTextNull)
+					continue
+				}
+				csvutils.CopyTextEscaper.WriteString(bw, sr.toString(val, types[i]))
+			}
+			return bw.WriteByte('\n')
+		})
+		if err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+}
+
+// holdsString reports whether an empty cell holds an empty string, like ="" does, rather
+// than being blank, which is loaded as null.
+func (sr *sheetReader) holdsString(rowNum, col int) bool {
+	cell, err := excelize.CoordinatesToCellName(sr.rng.firstCol+col, rowNum)
+	if err != nil {
+		return false
+	}
+	tp, err := sr.f.GetCellType(sr.sheet, cell)
+	if err != nil {
+		return false
+	}
+	return tp == excelize.CellTypeSharedString || tp == excelize.CellTypeInlineString || tp == excelize.CellTypeFormula
+}
+
+// sample returns the header row and the row numbers of the first lookUp data rows.
+func (sr *sheetReader) sample(lookUp int) ([]string, []int, error) {
+	var headers []string
+	var samples []int
+
+	errStop := builterr.New("stop")
+	err := sr.eachRow(func(rowNum int, cells []string) error {
+		if rowNum < sr.headerRow {
+			return nil
+		}
+		if rowNum == sr.headerRow {
+			headers = buildHeaders(cells, sr.rng.firstCol)
+			return nil
+		}
+		if len(samples) == lookUp {
+			return errStop
+		}
+		samples = append(samples, rowNum)
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, nil, err
+	}
+	if len(headers) == 0 {
+		return nil, nil, fmt.Errorf("header row %d is empty", sr.headerRow)
+	}
+	return headers, samples, nil
+}
+
+// eachRow streams the non-empty rows within the sheet range, cut to the header width once it is known.
+func (sr *sheetReader) eachRow(fn func(rowNum int, cells []string) error) error {
+	rows, err := sr.f.Rows(sr.sheet)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// The header row decides how many columns every row has.
+	width := 0
+	if sr.rng.lastCol > 0 {
+		width = sr.rng.lastCol - sr.rng.firstCol + 1
+	}
+
+	rowNum := 0
+	for rows.Next() {
+		rowNum++
+		if rowNum < sr.rng.firstRow {
+			continue
+		}
+		if sr.rng.lastRow > 0 && rowNum > sr.rng.lastRow {
+			break
+		}
+
+		// Raw values keep numbers and dates as they are stored instead of how they are displayed.
+		all, err := rows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return err
+		}
+		var cells []string
+		if len(all) >= sr.rng.firstCol {
+			cells = all[sr.rng.firstCol-1:]
+		}
+		if rowNum == sr.headerRow && width == 0 {
+			width = len(cells)
+		}
+		if width > 0 {
+			cells = append(cells, make([]string, max(0, width-len(cells)))...)[:width]
+		}
+		if isEmpty(cells) && rowNum != sr.headerRow {
+			continue
+		}
+		if err = fn(rowNum, cells); err != nil {
+			return err
+		}
+	}
+	return rows.Error()
+}
+
+// columnType finds the column type from the sampled cells' Excel types and number formats.
+func (sr *sheetReader) columnType(col int, samples []int) string {
+	typesCnt := map[string]int{}
+	for _, rowNum := range samples {
+		cell, err := excelize.CoordinatesToCellName(sr.rng.firstCol+col, rowNum)
+		if err != nil {
+			return dbv2.Text
+		}
+		val, err := sr.f.GetCellValue(sr.sheet, cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return dbv2.Text
+		}
+		if val == "" {
+			continue
+		}
+		typesCnt[sr.cellType(cell, val)]++
+	}
+
+	if len(typesCnt) == 1 {
+		for tp := range typesCnt {
+			return tp
+		}
+	}
+	// Dates with and without a time part fit in a timestamp column.
+	if len(typesCnt) == 2 && typesCnt[dbv2.Date] > 0 && typesCnt[dbv2.Timestamp] > 0 {
+		return dbv2.Timestamp
+	}
+	return dbv2.Text
+}
+
+func (sr *sheetReader) cellType(cell, val string) string {
+	ct, err := sr.f.GetCellType(sr.sheet, cell)
+	if err != nil {
+		return dbv2.Text
+	}
+	switch ct {
+	case excelize.CellTypeBool:
+		return dbv2.Boolean
+	case excelize.CellTypeDate:
+		return dbv2.Timestamp
+	case excelize.CellTypeUnset, excelize.CellTypeNumber, excelize.CellTypeFormula:
+		num, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return dbv2.Text
+		}
+		if !sr.isDateFormatted(cell) {
+			return dbv2.Numeric
+		}
+		switch {
+		case num < 1:
+			return dbv2.Time
+		case num == float64(int64(num)):
+			return dbv2.Date
+		default:
+			return dbv2.Timestamp
+		}
+	default:
+		return dbv2.Text
+	}
+}
+
+func (sr *sheetReader) isDateFormatted(cell string) bool {
+	idx, err := sr.f.GetCellStyle(sr.sheet, cell)
+	if err != nil {
+		return false
+	}
+	style, err := sr.f.GetStyle(idx)
+	if err != nil {
+		return false
+	}
+	if style.CustomNumFmt == nil {
+		return builtInDateFormats[style.NumFmt]
+	}
+	format := strings.ToLower(numFmtNoise.ReplaceAllString(*style.CustomNumFmt, ""))
+	return strings.ContainsAny(format, "ydhs")
+}
+
+func (sr *sheetReader) toString(val, pgType string) string {
+	if val == "" {
+		return val
+	}
+	switch pgType {
+	case dbv2.Boolean:
+		// Boolean cells store 1 and 0.
+		return strconv.FormatBool(val == "1" || strings.EqualFold(val, "true"))
+	case dbv2.Date, dbv2.Time, dbv2.Timestamp:
+		serial, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			// ISO 8601 date cells are already readable by PostgreSQL.
+			return val
+		}
+		t, err := excelize.ExcelDateToTime(serial, sr.date1904)
+		if err != nil {
+			return val
+		}
+		switch pgType {
+		case dbv2.Date:
+			return t.Format(time.DateOnly)
+		case dbv2.Time:
+			return t.Format("15:04:05.999999")
+		}
+		return t.Format(timestampLayout)
+	}
+	return val
+}
+
+func parseRange(rng string) (sheetRange, error) {
+	res := sheetRange{firstCol: 1, firstRow: 1}
+	if rng == "" {
+		return res, nil
+	}
+	first, last, _ := strings.Cut(strings.ToUpper(rng), ":")
+	var err error
+	if res.firstCol, res.firstRow, err = excelize.CellNameToCoordinates(first); err != nil {
+		return res, fmt.Errorf("invalid cell range %q: %w", rng, err)
+	}
+	if last == "" {
+		return res, nil
+	}
+	if res.lastCol, res.lastRow, err = excelize.CellNameToCoordinates(last); err != nil {
+		return res, fmt.Errorf("invalid cell range %q: %w", rng, err)
+	}
+	if res.lastCol < res.firstCol || res.lastRow < res.firstRow {
+		return res, fmt.Errorf("invalid cell range %q: end cell comes before start cell", rng)
+	}
+	return res, nil
+}
+
+// buildHeaders names empty header cells after their column letter and makes the names unique.
+func buildHeaders(cells []string, firstCol int) []string {
+	headers := make([]string, len(cells))
+	seen := map[string]int{}
+	for i, cell := range cells {
+		header := strings.TrimSpace(cell)
+		if header == "" {
+			letter, _ := excelize.ColumnNumberToName(firstCol + i)
+			header = "column_" + strings.ToLower(letter)
+		}
+		if n := seen[header]; n > 0 {
+			seen[header]++
+			header = fmt.Sprintf("%s_%d", header, n+1)
+		} else {
+			seen[header] = 1
+		}
+		headers[i] = header
+	}
+	return headers
+}
+
+func isEmpty(cells []string) bool {
+	for _, c := range cells {
+		if c != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func printError(f, sheet, name string, err error) {
+	fmt.Printf(`status=FAILED data_format="XLSX" msg="unable to load" file=%q sheet=%q name=%q error=%q`+"\n", f, sheet, name, err.Error())
+}
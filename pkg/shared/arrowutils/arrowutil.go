@@ -0,0 +1,152 @@
+package arrowutils
+
+import (
+	"bufio"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/anvesh9652/pgload/pkg/shared/csvutils"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+)
+
+var arrayEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// ColumnTypes builds the table columns straight from an Arrow schema, so no sampling is needed.
+func ColumnTypes(schema *arrow.Schema, typeSetting string) ([]string, []string) {
+	var colsTypes, cols []string
+	for _, field := range schema.Fields() {
+		cols = append(cols, field.Name)
+		tp := dbv2.Text
+		if typeSetting != shared.AllText {
+			tp = PGType(field.Type)
+		}
+		colsTypes = append(colsTypes, strconv.Quote(field.Name)+" "+tp)
+	}
+	return colsTypes, cols
+}
+
+// PGType maps an Arrow data type to the closest PostgreSQL column type.
+func PGType(dt arrow.DataType) string {
+	switch t := dt.(type) {
+	case *arrow.BooleanType:
+		return dbv2.Boolean
+	case *arrow.Int8Type, *arrow.Int16Type, *arrow.Uint8Type:
+		return dbv2.SmallInt
+	case *arrow.Int32Type, *arrow.Uint16Type:
+		return dbv2.Integer
+	case *arrow.Int64Type, *arrow.Uint32Type:
+		return dbv2.BigInt
+	case *arrow.Uint64Type:
+		return dbv2.NumericOf(20, 0)
+	case *arrow.Float16Type, *arrow.Float32Type:
+		return dbv2.Real
+	case *arrow.Float64Type:
+		return dbv2.Double
+	case arrow.DecimalType:
+		return dbv2.NumericOf(t.GetPrecision(), t.GetScale())
+	case *arrow.Date32Type, *arrow.Date64Type:
+		return dbv2.Date
+	case *arrow.Time32Type, *arrow.Time64Type:
+		return dbv2.Time
+	case *arrow.TimestampType:
+		if t.TimeZone != "" {
+			return dbv2.TimestampTZ
+		}
+		return dbv2.Timestamp
+	case *arrow.BinaryType, *arrow.LargeBinaryType, *arrow.BinaryViewType, *arrow.FixedSizeBinaryType:
+		return dbv2.Bytea
+	case *arrow.MapType, *arrow.StructType:
+		return dbv2.Jsonb
+	case arrow.ListLikeType:
+		// PostgreSQL arrays must be rectangular, so only lists of scalars become arrays.
+		if _, nested := t.Elem().(arrow.NestedType); nested {
+			return dbv2.Jsonb
+		}
+		return PGType(t.Elem()) + "[]"
+	case *arrow.DictionaryType:
+		return PGType(t.ValueType)
+	default:
+		return dbv2.Text
+	}
+}
+
+// WriteRecordText writes every row of the record in the text format of COPY:
+// tab separated values with \N for nulls, which keeps empty strings apart from nulls.
+func WriteRecordText(w *bufio.Writer, rec arrow.RecordBatch) error {
+	cols := rec.Columns()
+	for i := range int(rec.NumRows()) {
+		for c, col := range cols {
+			if c > 0 {
+				w.WriteByte('\t')
+			}
+			if isNull(col, i) {
+				w.WriteString(csvutils.CopyTextNull)
+				continue
+			}
+			csvutils.CopyTextEscaper.WriteString(w, ValueString(col, i))
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValueString formats the i-th value of arr the way COPY parses it for the column type from PGType.
+func ValueString(arr arrow.Array, i int) string {
+	if arr.IsNull(i) {
+		return ""
+	}
+	switch a := arr.(type) {
+	case *array.Dictionary:
+		return ValueString(a.Dictionary(), a.GetValueIndex(i))
+	case *array.Map:
+		return a.ValueStr(i)
+	case array.ListLike:
+		if _, nested := a.ListValues().DataType().(arrow.NestedType); !nested {
+			return arrayLiteral(a, i)
+		}
+		return a.ValueStr(i)
+	}
+	// Binary arrays are the only ones whose values come back as raw bytes; send them as bytea hex.
+	if b, ok := arr.(interface{ Value(int) []byte }); ok {
+		return `\x` + hex.EncodeToString(b.Value(i))
+	}
+	return arr.ValueStr(i)
+}
+
+// arrayLiteral formats a list of scalars as a PostgreSQL array like {"1","2",NULL}.
+// Quoting every element keeps it valid for all element types.
+func arrayLiteral(l array.ListLike, i int) string {
+	start, end := l.ValueOffsets(i)
+	values := l.ListValues()
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for j := int(start); j < int(end); j++ {
+		if j > int(start) {
+			sb.WriteByte(',')
+		}
+		if isNull(values, j) {
+			sb.WriteString("NULL")
+			continue
+		}
+		sb.WriteByte('"')
+		arrayEscaper.WriteString(&sb, ValueString(values, j))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// isNull also checks the value a dictionary index points to.
+func isNull(arr arrow.Array, i int) bool {
+	if d, ok := arr.(*array.Dictionary); ok && d.IsValid(i) {
+		return d.Dictionary().IsNull(d.GetValueIndex(i))
+	}
+	return arr.IsNull(i)
+}
@@ -0,0 +1,10 @@
+package csvutils
+
+import "strings"
+
+// Null marker of the text format of COPY.
+const CopyTextNull = `\N`
+
+// CopyTextEscaper escapes a value for the text format of COPY, where backslashes and
+// the tab and newline characters separating values and rows have to be escaped.
+var CopyTextEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
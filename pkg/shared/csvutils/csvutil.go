@@ -3,6 +3,7 @@ package csvutils
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -33,17 +34,22 @@ func BuildColumnTypeStr(types map[string]string) (res []string) {
 	return
 }
 
-func FindColumnTypes(path string, lookUpSize int, typeSetting *string) (map[string]string, error) {
-	r, err := reader.NewFileGzipReader(path)
+func FindColumnTypes(ctx context.Context, path string, lookUpSize int, typeSetting *string, d Dialect) (map[string]string, error) {
+	r, err := reader.NewFileGzipReaderContext(ctx, path)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
-	headers, br, err := GetCSVHeaders(r)
-	csvr := csv.NewReader(br)
+	return FindColumnTypesIn(r, lookUpSize, typeSetting, d)
+}
+
+// FindColumnTypesIn is like FindColumnTypes for CSV data that isn't read from a file.
+func FindColumnTypesIn(r io.Reader, lookUpSize int, typeSetting *string, d Dialect) (map[string]string, error) {
+	headers, br, err := GetCSVHeaders(r, d)
 	if err != nil {
 		return nil, err
 	}
+	csvr := NewReader(br, d)
 
 	var lookUpRows [][]string
 	for range lookUpSize {
@@ -62,6 +68,10 @@ func FindColumnTypes(path string, lookUpSize int, typeSetting *string) (map[stri
 	for i, col := range headers {
 		typesCnt := map[string]int{}
 		for ix := range rowsCount {
+			// Short rows are left for COPY to reject.
+			if i >= len(lookUpRows[ix]) {
+				continue
+			}
 			val := lookUpRows[ix][i]
 			if val != "" {
 				typesCnt[findType(val, typeSetting)] += 1
@@ -99,28 +109,29 @@ func findType(val string, typeSetting *string) string {
 	return dbv2.Text
 }
 
-func GetCSVHeaders(r io.Reader) ([]string, io.Reader, error) {
-	// Didn't find the best way to get only the first row.
-	// No need to worry here if `br` reads more than the first row.
+// GetCSVHeaders reads the header row and returns a reader positioned right after it.
+func GetCSVHeaders(r io.Reader, d Dialect) ([]string, io.Reader, error) {
 	br := bufio.NewReader(r)
-	buff := bytes.NewBuffer(nil)
-	for {
-		line, prefix, err := br.ReadLine()
+	if !d.NoHeader {
+		headers, err := NewReader(br, d).Read()
 		if err != nil {
-			return nil, nil, err
-		}
-		buff.Write(line)
-		if !prefix {
-			break
+			return nil, nil, fmt.Errorf("failed to read first line: %v", err)
 		}
+		return preserveExactColNames(headers), br, nil
 	}
-	csvR := csv.NewReader(buff)
-	headers, err := csvR.Read()
+
+	// Without a header row the first record is data, so everything read to find
+	// the number of columns is replayed in front of the rest.
+	consumed := bytes.NewBuffer(nil)
+	record, err := NewReader(io.TeeReader(br, consumed), d).Read()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read first line: %v", err)
 	}
-
-	return preserveExactColNames(headers), br, nil
+	headers := make([]string, len(record))
+	for i := range record {
+		headers[i] = fmt.Sprintf("column_%d", i+1)
+	}
+	return preserveExactColNames(headers), io.MultiReader(consumed, br), nil
 }
 
 // Preserve the exact column names by quoting them.
@@ -0,0 +1,200 @@
+package csvutils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anvesh9652/pgload/pkg/shared"
+)
+
+// Dialect describes how the fields of a CSV-like file are separated and quoted.
+// The same settings are used for header parsing, type inference and the COPY command.
+type Dialect struct {
+	Delimiter rune
+	Quote     rune
+	// Character that escapes a quote inside a quoted field. PostgreSQL defaults it to the quote,
+	// so a quote is escaped by doubling it.
+	Escape rune
+	// The first record holds data instead of column names.
+	NoHeader bool
+	// NoHeader was given explicitly, with --header or --no-header, so it isn't sniffed.
+	HeaderSet bool
+	// Only reported, both the Reader and COPY accept "\n", "\r\n" and "\r".
+	LineTerminator string
+}
+
+var DefaultDialect = Dialect{Delimiter: ',', Quote: '"', Escape: '"'}
+
+// Default delimiters for the extensions we recognize as CSV-like.
+var extensionDelimiters = map[string]rune{
+	"csv": ',',
+	"tsv": '\t',
+	"psv": '|',
+}
+
+// ForFile fills in the settings that weren't given explicitly, picking the delimiter
+// from the file extension.
+func (d Dialect) ForFile(file string) Dialect {
+	if d.Delimiter == 0 {
+		d.Delimiter = DefaultDialect.Delimiter
+		ns := strings.Split(shared.TrimCompressionSuffix(strings.ToLower(shared.InputName(file))), ".")
+		if delim, ok := extensionDelimiters[ns[len(ns)-1]]; ok {
+			d.Delimiter = delim
+		}
+	}
+	if d.Quote == 0 {
+		d.Quote = DefaultDialect.Quote
+	}
+	if d.Escape == 0 {
+		d.Escape = d.Quote
+	}
+	return d
+}
+
+func (d Dialect) String() string {
+	s := fmt.Sprintf("delimiter=%q quote=%q escape=%q header=%t", string(d.Delimiter), string(d.Quote), string(d.Escape), !d.NoHeader)
+	if d.LineTerminator != "" {
+		s += fmt.Sprintf(" line_terminator=%q", d.LineTerminator)
+	}
+	return s
+}
+
+func (d Dialect) Validate() error {
+	if d.Delimiter == d.Quote {
+		return fmt.Errorf("delimiter and quote can't be the same character %q", d.Delimiter)
+	}
+	for _, r := range []rune{d.Delimiter, d.Quote, d.Escape} {
+		if r == '\n' || r == '\r' {
+			return errors.New("delimiter, quote and escape can't be a newline")
+		}
+		// COPY only accepts single-byte characters.
+		if r >= utf8.RuneSelf {
+			return fmt.Errorf("%q is not a single-byte character", r)
+		}
+	}
+	return nil
+}
+
+// ParseDialectChar reads a delimiter, quote or escape flag value. Besides a single character
+// it accepts `\t` and the names "tab", "pipe", "comma" and "semicolon".
+func ParseDialectChar(val string) (rune, error) {
+	switch strings.ToLower(val) {
+	case "":
+		return 0, nil
+	case `\t`, "tab":
+		return '\t', nil
+	case "pipe":
+		return '|', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	}
+	if utf8.RuneCountInString(val) != 1 {
+		return 0, fmt.Errorf("%q must be a single character", val)
+	}
+	r, _ := utf8.DecodeRuneInString(val)
+	return r, nil
+}
+
+// Reader reads records of a CSV-like file with the quoting rules PostgreSQL's COPY uses
+// in CSV mode. It only consumes the bytes of the records it returns.
+type Reader struct {
+	br *bufio.Reader
+	d  Dialect
+}
+
+func NewReader(r io.Reader, d Dialect) *Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Reader{br: br, d: d}
+}
+
+// Read returns the next record, skipping empty lines.
+func (r *Reader) Read() ([]string, error) {
+	for {
+		record, err := r.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+		return record, nil
+	}
+}
+
+func (r *Reader) readRecord() ([]string, error) {
+	var (
+		fields   []string
+		field    strings.Builder
+		inQuotes bool
+		readAny  bool
+	)
+	for {
+		c, _, err := r.br.ReadRune()
+		if err != nil {
+			if err != io.EOF || !readAny {
+				return nil, err
+			}
+			if inQuotes {
+				return nil, errors.New("unterminated quoted field at end of file")
+			}
+			return append(fields, field.String()), nil
+		}
+		readAny = true
+
+		if inQuotes {
+			switch {
+			case c == r.d.Escape && r.d.Escape != r.d.Quote:
+				next, _, err := r.br.ReadRune()
+				if err == nil && (next == r.d.Quote || next == r.d.Escape) {
+					field.WriteRune(next)
+					continue
+				}
+				if err == nil {
+					_ = r.br.UnreadRune()
+				}
+				field.WriteRune(c)
+			case c == r.d.Quote:
+				// A doubled quote is a literal quote when the escape is the quote itself.
+				next, _, err := r.br.ReadRune()
+				if err == nil && next == r.d.Quote && r.d.Escape == r.d.Quote {
+					field.WriteRune(c)
+					continue
+				}
+				if err == nil {
+					_ = r.br.UnreadRune()
+				}
+				inQuotes = false
+			default:
+				field.WriteRune(c)
+			}
+			continue
+		}
+
+		switch c {
+		case r.d.Quote:
+			inQuotes = true
+		case r.d.Delimiter:
+			fields = append(fields, field.String())
+			field.Reset()
+		case '\r':
+			// Old Mac style files end lines with a lone "\r".
+			if next, _, err := r.br.ReadRune(); err == nil && next != '\n' {
+				_ = r.br.UnreadRune()
+			}
+			return append(fields, field.String()), nil
+		case '\n':
+			return append(fields, field.String()), nil
+		default:
+			field.WriteRune(c)
+		}
+	}
+}
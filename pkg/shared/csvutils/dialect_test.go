@@ -0,0 +1,146 @@
+package csvutils
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, input string, d Dialect) ([][]string, error) {
+	t.Helper()
+	r := NewReader(strings.NewReader(input), d)
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+func TestReader(t *testing.T) {
+	backslash := Dialect{Delimiter: ',', Quote: '"', Escape: '\\'}
+	tests := []struct {
+		name  string
+		input string
+		d     Dialect
+		want  [][]string
+	}{
+		{"plain", "a,b,c\n1,2,3\n", DefaultDialect, [][]string{{"a", "b", "c"}, {"1", "2", "3"}}},
+		{"no trailing newline", "a,b\n1,2", DefaultDialect, [][]string{{"a", "b"}, {"1", "2"}}},
+		{"empty fields", "a,,\n,b,\n", DefaultDialect, [][]string{{"a", "", ""}, {"", "b", ""}}},
+		{"empty lines skipped", "a\n\n\nb\n", DefaultDialect, [][]string{{"a"}, {"b"}}},
+		{"crlf", "a,b\r\n1,2\r\n", DefaultDialect, [][]string{{"a", "b"}, {"1", "2"}}},
+		{"lone cr", "a,b\r1,2\r", DefaultDialect, [][]string{{"a", "b"}, {"1", "2"}}},
+		{"quoted delimiter", `a,"b,c",d`, DefaultDialect, [][]string{{"a", "b,c", "d"}}},
+		{"quoted newline", "\"a\nb\",c\n", DefaultDialect, [][]string{{"a\nb", "c"}}},
+		{"doubled quote", `"say ""hi""",x`, DefaultDialect, [][]string{{`say "hi"`, "x"}}},
+		{"empty quoted field", `"",x`, DefaultDialect, [][]string{{"", "x"}}},
+		{"backslash escaped quote", `"say \"hi\"",x`, backslash, [][]string{{`say "hi"`, "x"}}},
+		{"backslash escaped backslash", `"a\\b",x`, backslash, [][]string{{`a\b`, "x"}}},
+		{"backslash kept before others", `"a\nb",x`, backslash, [][]string{{`a\nb`, "x"}}},
+		{"backslash unquoted", `a\b,x`, backslash, [][]string{{`a\b`, "x"}}},
+		{"quote inside field", `a"b,c"d,e`, DefaultDialect, [][]string{{"ab,cd", "e"}}},
+		{"tab delimiter", "a\tb c\n", Dialect{Delimiter: '\t', Quote: '"', Escape: '"'}, [][]string{{"a", "b c"}}},
+		{"single quote", "'a,b',c\n", Dialect{Delimiter: ',', Quote: '\'', Escape: '\''}, [][]string{{"a,b", "c"}}},
+		{"multibyte values", "café,naïve\n", DefaultDialect, [][]string{{"café", "naïve"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(t, tt.input, tt.d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderUnterminatedQuote(t *testing.T) {
+	got, err := readAll(t, "a,b\n\"c,d\n", DefaultDialect)
+	if err == nil {
+		t.Fatalf("got %q, want an error", got)
+	}
+}
+
+func TestDialectForFile(t *testing.T) {
+	tests := []struct {
+		file string
+		d    Dialect
+		want Dialect
+	}{
+		{"data.csv", Dialect{}, DefaultDialect},
+		{"data.tsv", Dialect{}, Dialect{Delimiter: '\t', Quote: '"', Escape: '"'}},
+		{"data.PSV", Dialect{}, Dialect{Delimiter: '|', Quote: '"', Escape: '"'}},
+		{"data.tsv.gz", Dialect{}, Dialect{Delimiter: '\t', Quote: '"', Escape: '"'}},
+		{"data.txt", Dialect{}, DefaultDialect},
+		{"data.tsv", Dialect{Delimiter: ';'}, Dialect{Delimiter: ';', Quote: '"', Escape: '"'}},
+		{"data.csv", Dialect{Quote: '\''}, Dialect{Delimiter: ',', Quote: '\'', Escape: '\''}},
+		{"data.csv", Dialect{Escape: '\\'}, Dialect{Delimiter: ',', Quote: '"', Escape: '\\'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := tt.d.ForFile(tt.file); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       Dialect
+		wantErr bool
+	}{
+		{"default", DefaultDialect, false},
+		{"tab and backslash", Dialect{Delimiter: '\t', Quote: '"', Escape: '\\'}, false},
+		{"delimiter is quote", Dialect{Delimiter: '"', Quote: '"', Escape: '"'}, true},
+		{"newline delimiter", Dialect{Delimiter: '\n', Quote: '"', Escape: '"'}, true},
+		{"carriage return escape", Dialect{Delimiter: ',', Quote: '"', Escape: '\r'}, true},
+		{"multibyte delimiter", Dialect{Delimiter: '§', Quote: '"', Escape: '"'}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.d.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDialectChar(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    rune
+		wantErr bool
+	}{
+		{"", 0, false},
+		{",", ',', false},
+		{`\t`, '\t', false},
+		{"tab", '\t', false},
+		{"TAB", '\t', false},
+		{"pipe", '|', false},
+		{"comma", ',', false},
+		{"semicolon", ';', false},
+		{"é", 'é', false},
+		{"ab", 0, true},
+		{`\n`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			got, err := ParseDialectChar(tt.val)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("got %q, %v, want %q, error %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package csvutils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"slices"
+
+	"github.com/anvesh9652/pgload/internal/pgdb/dbv2"
+	"github.com/anvesh9652/pgload/pkg/shared/reader"
+)
+
+const (
+	sniffSize = 64 * 1024
+	// Number of records parsed from the sample to compare candidates.
+	sniffRecords = 100
+)
+
+var (
+	delimiterCandidates = []rune{',', '\t', ';', '|'}
+	quoteCandidates     = []rune{'"', '\''}
+)
+
+// DetectDialect sniffs the settings that weren't given explicitly from the start of the file.
+func DetectDialect(ctx context.Context, path string, d Dialect) (Dialect, error) {
+	r, err := reader.NewFileGzipReaderContext(ctx, path)
+	if err != nil {
+		return d, err
+	}
+	defer r.Close()
+
+	sample := make([]byte, sniffSize)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return d, err
+	}
+	return Sniff(sample[:n], n == sniffSize, d, path), nil
+}
+
+// Sniff detects the delimiter, quote, header presence and line terminator from a sample.
+// Settings already present in d are kept, and the extension of file breaks ties between delimiters.
+// truncated tells whether the sample stops in the middle of the data.
+func Sniff(sample []byte, truncated bool, d Dialect, file string) Dialect {
+	defaults := d.ForFile(file)
+	if d.Quote == 0 {
+		d.Quote = sniffQuote(sample, defaults.Quote)
+	}
+	if d.Escape == 0 {
+		d.Escape = d.Quote
+	}
+	if d.Delimiter == 0 {
+		d.Delimiter = sniffDelimiter(sample, truncated, d, defaults.Delimiter)
+	}
+	d.LineTerminator = sniffLineTerminator(sample)
+	if !d.HeaderSet {
+		d.NoHeader = !hasHeader(sampleRecords(sample, truncated, d))
+	}
+	return d
+}
+
+// sniffQuote counts the quote candidates that open a field and picks the most used one.
+func sniffQuote(sample []byte, fallback rune) rune {
+	best, bestCount := fallback, 0
+	for _, q := range quoteCandidates {
+		count := 0
+		for i, b := range sample {
+			if rune(b) == q && (i == 0 || isFieldStart(sample[i-1])) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = q, count
+		}
+	}
+	return best
+}
+
+func isFieldStart(prev byte) bool {
+	return prev == '\n' || prev == '\r' || slices.Contains(delimiterCandidates, rune(prev))
+}
+
+// sniffDelimiter picks the candidate that splits the most records into the same number of fields.
+// The header is part of the sample, so a character that only shows up in values, like a decimal comma,
+// loses to the real delimiter.
+func sniffDelimiter(sample []byte, truncated bool, d Dialect, fallback rune) rune {
+	// The fallback goes first so it wins ties.
+	candidates := append([]rune{fallback}, delimiterCandidates...)
+
+	best, bestScore := fallback, 0.0
+	for _, candidate := range candidates {
+		if candidate == d.Quote {
+			continue
+		}
+		d.Delimiter = candidate
+		records := sampleRecords(sample, truncated, d)
+		if len(records) == 0 {
+			continue
+		}
+
+		counts := map[int]int{}
+		for _, record := range records {
+			counts[len(record)]++
+		}
+		fields, freq := 0, 0
+		for n, f := range counts {
+			if f > freq || (f == freq && n > fields) {
+				fields, freq = n, f
+			}
+		}
+		if fields < 2 {
+			continue
+		}
+		if score := float64(freq) / float64(len(records)); score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// sniffLineTerminator reports the terminator of the first line, which is also what COPY goes by.
+func sniffLineTerminator(sample []byte) string {
+	i := bytes.IndexAny(sample, "\r\n")
+	switch {
+	case i == -1, sample[i] == '\n':
+		return "\n"
+	case i+1 < len(sample) && sample[i+1] == '\n':
+		return "\r\n"
+	}
+	return "\r"
+}
+
+// hasHeader tells whether the first record is a header, which is assumed unless the first
+// record is clearly data: a column of numbers starts with a decimal, signed or exponent number,
+// which column names never are, and no column of numbers starts with a name. Names that look
+// like values, like country codes over country codes or years over amounts, keep the header.
+func hasHeader(records [][]string) bool {
+	if len(records) < 2 {
+		return true
+	}
+
+	data, names := false, false
+	for i, first := range records[0] {
+		numeric, found := true, false
+		for _, record := range records[1:] {
+			if i >= len(record) || record[i] == "" {
+				continue
+			}
+			found = true
+			if findType(record[i], nil) != dbv2.Numeric {
+				numeric = false
+				break
+			}
+		}
+		if !found || !numeric || first == "" {
+			continue
+		}
+		switch {
+		case findType(first, nil) != dbv2.Numeric:
+			names = true
+		case dataNumber.MatchString(first):
+			data = true
+		}
+	}
+	return names || !data
+}
+
+// Decimal, signed or exponent numbers, like 3.14, -7 or 1e6, but not integers like 2019.
+var dataNumber = regexp.MustCompile(`^(?:[+-]\d+\.?\d*|[+-]?(?:\d+\.\d*|\.\d+|\d+(?:\.\d*)?[eE][+-]?\d+))$`)
+
+// sampleRecords parses the records of the sample, dropping the last one when the sample
+// may have cut it short.
+func sampleRecords(sample []byte, truncated bool, d Dialect) [][]string {
+	r := NewReader(bytes.NewReader(sample), d)
+	var records [][]string
+	for len(records) < sniffRecords {
+		record, err := r.Read()
+		if err == io.EOF {
+			if truncated && len(records) > 0 {
+				records = records[:len(records)-1]
+			}
+			break
+		}
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	return records
+}
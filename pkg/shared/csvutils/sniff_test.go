@@ -0,0 +1,163 @@
+package csvutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name      string
+		sample    string
+		truncated bool
+		d         Dialect
+		file      string
+		want      Dialect
+	}{
+		{
+			name:   "comma",
+			sample: "id,name,price\n1,apple,1.5\n2,pear,2\n",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ',', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+		{
+			name:   "semicolon with decimal commas",
+			sample: "name;price\nfoo;1,5\nbar;2,25\nbaz;3,75\n",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ';', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+		{
+			name:   "tab in a csv file",
+			sample: "a\tb\tc\n1\tx\t3\n",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: '\t', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+		{
+			name:   "pipe with commas in values",
+			sample: "name|note\nbob|a, b\nann|c, d, e\n",
+			file:   "a.txt",
+			want:   Dialect{Delimiter: '|', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+		{
+			name:   "quoted delimiters",
+			sample: "id,addr\n1,\"1 Main St; Apt 2\"\n2,\"3 Oak Rd; Unit 4\"\n",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ',', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+		{
+			name:   "single quotes",
+			sample: "'id','name'\n'1','a,b'\n'2','c'\n",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ',', Quote: '\'', Escape: '\'', LineTerminator: "\n"},
+		},
+		{
+			name:   "single column falls back to the extension",
+			sample: "name\nbob\nann\n",
+			file:   "a.tsv",
+			want:   Dialect{Delimiter: '\t', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+		{
+			name:   "crlf",
+			sample: "a,b\r\n1,2\r\n",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ',', Quote: '"', Escape: '"', LineTerminator: "\r\n"},
+		},
+		{
+			name:   "cr",
+			sample: "a,b\r1,2\r",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ',', Quote: '"', Escape: '"', LineTerminator: "\r"},
+		},
+		{
+			name:   "no header",
+			sample: "1.5,-2\n3.25,4\n",
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ',', Quote: '"', Escape: '"', NoHeader: true, LineTerminator: "\n"},
+		},
+		{
+			name:   "header given",
+			sample: "1.5,-2\n3.25,4\n",
+			d:      Dialect{HeaderSet: true},
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ',', Quote: '"', Escape: '"', HeaderSet: true, LineTerminator: "\n"},
+		},
+		{
+			name:   "explicit settings kept",
+			sample: "a,b\n1,2\n",
+			d:      Dialect{Delimiter: ';', Quote: '\'', Escape: '\\'},
+			file:   "a.csv",
+			want:   Dialect{Delimiter: ';', Quote: '\'', Escape: '\\', LineTerminator: "\n"},
+		},
+		{
+			name:      "truncated sample",
+			sample:    "a,b\n1,2\n3,4\n5,6\n3",
+			truncated: true,
+			file:      "a.csv",
+			want:      Dialect{Delimiter: ',', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+		{
+			name:   "empty",
+			sample: "",
+			file:   "a.psv",
+			want:   Dialect{Delimiter: '|', Quote: '"', Escape: '"', LineTerminator: "\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff([]byte(tt.sample), tt.truncated, tt.d, tt.file); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]string
+		want    bool
+	}{
+		{"no records", nil, true},
+		{"one record", [][]string{{"1.5", "2.5"}}, true},
+		{"names over numbers", [][]string{{"id", "price"}, {"1", "9.99"}, {"2", "5"}}, true},
+		{"decimals", [][]string{{"1.5", "2.5"}, {"3.5", "4.5"}}, false},
+		{"signed number", [][]string{{"x", "-7"}, {"y", "3"}}, false},
+		{"exponent", [][]string{{"1e6"}, {"2"}}, false},
+		{"years over amounts", [][]string{{"2019", "2020"}, {"100", "200"}}, true},
+		{"codes over codes", [][]string{{"US", "FR"}, {"DE", "IT"}}, true},
+		{"text columns only", [][]string{{"bob", "ann"}, {"joe", "sue"}}, true},
+		{"a name wins over a decimal", [][]string{{"1.5", "price"}, {"2.5", "3"}}, true},
+		{"empty first values", [][]string{{"", "2.5"}, {"1", "3"}}, false},
+		{"empty data values", [][]string{{"1.5", "x"}, {"", "y"}, {"2", "z"}}, false},
+		{"short records", [][]string{{"id", "1.5"}, {"1"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasHeader(tt.records); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampleRecords(t *testing.T) {
+	tests := []struct {
+		name      string
+		sample    string
+		truncated bool
+		want      [][]string
+	}{
+		{"whole sample", "a,b\n1,2\n3", false, [][]string{{"a", "b"}, {"1", "2"}, {"3"}}},
+		{"cut last record dropped", "a,b\n1,2\n3", true, [][]string{{"a", "b"}, {"1", "2"}}},
+		{"cut inside quotes", "a,b\n1,\"x\ny", true, [][]string{{"a", "b"}}},
+		{"only a cut record", "a,b", true, [][]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sampleRecords([]byte(tt.sample), tt.truncated, DefaultDialect)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package reader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/anvesh9652/pgload/pkg/shared"
+)
+
+// A file inside a zip or tar archive.
+type Member struct {
+	// Path inside the archive, like exports/orders.csv.
+	Name string
+	// Size of the member as stored, before its own decompression.
+	Size int64
+}
+
+// ListArchive returns the regular files of a zip or tar archive, which may itself be
+// compressed like .tar.gz or .tar.zst. Hidden files, like the __MACOSX/ entries of
+// zips made on macOS, are skipped.
+func ListArchive(file string) ([]Member, error) {
+	var members []Member
+	err := walkArchive(context.Background(), file, func(name string, size int64, _ func() (io.ReadCloser, error)) (bool, error) {
+		members = append(members, Member{Name: name, Size: size})
+		return true, nil
+	})
+	return members, err
+}
+
+// Tar members that StreamTar is at, by member path, like bundle.tar.gz::exports/orders.csv.
+var streamed = struct {
+	sync.Mutex
+	members map[string]*replaySource
+}{members: map[string]*replaySource{}}
+
+// StreamTar reads a tar archive once, from its start to its end, and calls fn with the path of
+// every member, like bundle.tar.gz::exports/orders.csv, and its size. While fn runs, the
+// member can be opened any number of times: the readers replay the start of the member that
+// the earlier ones read, the way stdin is replayed, so nothing is extracted to disk. A remote
+// archive stops downloading once ctx is done.
+func StreamTar(ctx context.Context, file string, fn func(member string, size int64) error) error {
+	return walkTar(ctx, file, func(name string, size int64, open func() (io.ReadCloser, error)) (bool, error) {
+		// The member reader isn't closed, which would close the archive being walked.
+		r, err := open()
+		if err != nil {
+			return false, err
+		}
+		member := shared.ArchiveMemberPath(file, name)
+		src := &replaySource{
+			src:      r,
+			consumed: fmt.Errorf("%s was already read past the data kept for type inference; use a smaller --lookup", member),
+		}
+		streamed.Lock()
+		streamed.members[member] = src
+		streamed.Unlock()
+		defer func() {
+			streamed.Lock()
+			delete(streamed.members, member)
+			streamed.Unlock()
+			// The archive goes on with the next member, which readers left behind mustn't get.
+			src.mu.Lock()
+			src.kept, src.dropped = nil, true
+			src.consumed = fmt.Errorf("%s is no longer read, the archive went on", member)
+			src.mu.Unlock()
+		}()
+		return true, fn(member, size)
+	})
+}
+
+// openMember returns a reader of an archive member, decompressing it when it's compressed.
+// Zip members are opened directly, and the tar member that StreamTar is at is replayed.
+// Other tar members are read from the start of the archive up to the member, since tar has
+// no index.
+func openMember(ctx context.Context, archive, member string) (io.ReadCloser, error) {
+	streamed.Lock()
+	src, ok := streamed.members[shared.ArchiveMemberPath(archive, member)]
+	streamed.Unlock()
+	if ok {
+		return openReplay(src)
+	}
+
+	var rc io.ReadCloser
+	err := walkArchive(ctx, archive, func(name string, _ int64, open func() (io.ReadCloser, error)) (bool, error) {
+		if name != member {
+			return true, nil
+		}
+		var err error
+		rc, err = open()
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rc == nil {
+		return nil, fmt.Errorf("%s not found in %s", member, archive)
+	}
+	return decompress(rc, rc)
+}
+
+// walkArchive calls fn with every regular file of the archive until fn returns false.
+// The reader that open returns stays valid once walkArchive returns false, and closing
+// it closes the archive. Otherwise the archive is closed when walkArchive returns.
+func walkArchive(ctx context.Context, file string, fn func(name string, size int64, open func() (io.ReadCloser, error)) (bool, error)) error {
+	if strings.HasSuffix(shared.InputName(file), ".zip") {
+		return walkZip(file, fn)
+	}
+	return walkTar(ctx, file, fn)
+}
+
+func walkZip(file string, fn func(name string, size int64, open func() (io.ReadCloser, error)) (bool, error)) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	kept := false
+	defer func() {
+		if !kept {
+			zr.Close()
+		}
+	}()
+
+	for _, f := range zr.File {
+		name, ok := memberName(f.Name, f.FileInfo().Mode().IsRegular())
+		if !ok {
+			continue
+		}
+		open := func() (io.ReadCloser, error) {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			kept = true
+			return &closingReader{Reader: rc, closers: []io.Closer{rc, zr}}, nil
+		}
+		more, err := fn(name, int64(f.UncompressedSize64), open)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(ctx context.Context, file string, fn func(name string, size int64, open func() (io.ReadCloser, error)) (bool, error)) error {
+	r, err := NewFileGzipReaderContext(ctx, file)
+	if err != nil {
+		return err
+	}
+	kept := false
+	defer func() {
+		if !kept {
+			r.Close()
+		}
+	}()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := memberName(hdr.Name, hdr.Typeflag == tar.TypeReg)
+		if !ok {
+			continue
+		}
+		opened := false
+		open := func() (io.ReadCloser, error) {
+			opened = true
+			return &closingReader{Reader: tr, closers: []io.Closer{r}}, nil
+		}
+		more, err := fn(name, hdr.Size, open)
+		if err != nil || !more {
+			kept = opened && err == nil
+			return err
+		}
+	}
+}
+
+// memberName cleans the path of an archive entry, like ./exports/orders.csv, and reports
+// whether the entry is a regular file that isn't hidden.
+func memberName(name string, regular bool) (string, bool) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if !regular || name == "" {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// closingReader reads from Reader and closes all of closers, like an archive member
+// along with its archive.
+type closingReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *closingReader) Close() error {
+	var errs []error
+	for _, c := range m.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
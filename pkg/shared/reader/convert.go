@@ -0,0 +1,53 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+)
+
+var jsonNumberRe = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// ConvertingReader reads the output of a conversion that runs over src in its own goroutine.
+type ConvertingReader struct {
+	src  io.ReadCloser
+	pr   *io.PipeReader
+	done chan struct{}
+}
+
+func newConvertingReader(src io.ReadCloser, convert func(w *bufio.Writer) error) *ConvertingReader {
+	pr, pw := io.Pipe()
+	cr := &ConvertingReader{src: src, pr: pr, done: make(chan struct{})}
+	go func() {
+		defer close(cr.done)
+		w := bufio.NewWriterSize(pw, peekSize)
+		err := convert(w)
+		if err == nil {
+			err = w.Flush()
+		}
+		pw.CloseWithError(err)
+	}()
+	return cr
+}
+
+func (r *ConvertingReader) Read(p []byte) (int, error) {
+	return r.pr.Read(p)
+}
+
+func (r *ConvertingReader) Close() error {
+	// Closing the pipe stops the goroutine at its next write.
+	r.pr.Close()
+	<-r.done
+	return r.src.Close()
+}
+
+// textValue writes values that are valid JSON numbers as numbers, so they go through
+// the same type inference as JSON numbers. Anything else, like zip codes with
+// leading zeros, stays a string.
+func textValue(s string) any {
+	if jsonNumberRe.MatchString(s) {
+		return json.Number(s)
+	}
+	return s
+}
@@ -0,0 +1,274 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// GeometryKey is the key of the feature geometry in the rows of NewGeoJSONReader.
+const GeometryKey = "geometry"
+
+// SRID of WGS 84, the only coordinate reference system of RFC 7946.
+const DefaultSRID = 4326
+
+var epsgRe = regexp.MustCompile(`EPSG:+(\d+)$`)
+
+type feature struct {
+	ID         any             `json:"id"`
+	Properties map[string]any  `json:"properties"`
+	Geometry   json.RawMessage `json:"geometry"`
+}
+
+// NewGeoJSONReader streams the features of a GeoJSON FeatureCollection, or a single Feature,
+// as JSONL with one object per feature holding its properties, its id and its geometry.
+// Only one feature is decoded at a time. When srid is set, the geometry is written as EWKT,
+// like `SRID=4326;POINT(1 2)`, which PostGIS geometry columns read; otherwise it stays GeoJSON.
+func NewGeoJSONReader(src io.ReadCloser, srid int) io.ReadCloser {
+	return newConvertingReader(src, func(w *bufio.Writer) error {
+		return streamGeoJSON(src, srid, w)
+	})
+}
+
+func streamGeoJSON(r io.Reader, srid int, w *bufio.Writer) error {
+	iter := jsoniter.Parse(jsoniter.ConfigDefault, r, peekSize)
+	if iter.WhatIsNext() != jsoniter.ObjectValue {
+		return fmt.Errorf("expected a GeoJSON FeatureCollection or Feature object")
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	var (
+		err     error
+		typ     string
+		single  = map[string]json.RawMessage{}
+		streams bool
+	)
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "features":
+			streams = true
+			iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+				err = writeFeature(enc, iter.SkipAndReturnBytes(), srid)
+				return err == nil
+			})
+		case "type":
+			typ = iter.ReadString()
+		case "id", "properties", GeometryKey:
+			// Kept in case the document is a single Feature.
+			single[field] = json.RawMessage(iter.SkipAndReturnBytes())
+		default:
+			iter.Skip()
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = iterError(iter); err != nil {
+		return err
+	}
+
+	switch {
+	case streams:
+		return nil
+	case typ == "Feature":
+		raw, err := json.Marshal(single)
+		if err != nil {
+			return err
+		}
+		return writeFeature(enc, raw, srid)
+	}
+	return fmt.Errorf("expected a GeoJSON FeatureCollection or Feature, got type %q", typ)
+}
+
+func writeFeature(enc *json.Encoder, raw []byte, srid int) error {
+	var f feature
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&f); err != nil {
+		return fmt.Errorf("invalid feature: %v", err)
+	}
+
+	row := f.Properties
+	if row == nil {
+		row = map[string]any{}
+	}
+	if _, ok := row["id"]; !ok && f.ID != nil {
+		row["id"] = f.ID
+	}
+
+	var geom any
+	if len(f.Geometry) > 0 && string(f.Geometry) != "null" {
+		geom = f.Geometry
+		if srid != 0 {
+			var sb strings.Builder
+			sb.WriteString("SRID=" + strconv.Itoa(srid) + ";")
+			if err := writeWKT(&sb, f.Geometry); err != nil {
+				return err
+			}
+			geom = sb.String()
+		}
+	}
+	row[GeometryKey] = geom
+	return enc.Encode(row)
+}
+
+type geometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []json.RawMessage `json:"geometries"`
+}
+
+// Nesting of the coordinates of every geometry type: positions, lists of positions, and so on.
+var coordsDepth = map[string]int{
+	"Point":           0,
+	"MultiPoint":      1,
+	"LineString":      1,
+	"Polygon":         2,
+	"MultiLineString": 2,
+	"MultiPolygon":    3,
+}
+
+// writeWKT writes a GeoJSON geometry as WKT.
+func writeWKT(sb *strings.Builder, raw json.RawMessage) error {
+	var g geometry
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return fmt.Errorf("invalid geometry: %v", err)
+	}
+
+	if g.Type == "GeometryCollection" {
+		sb.WriteString("GEOMETRYCOLLECTION")
+		if len(g.Geometries) == 0 {
+			sb.WriteString(" EMPTY")
+			return nil
+		}
+		sb.WriteByte('(')
+		for i, child := range g.Geometries {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err := writeWKT(sb, child); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte(')')
+		return nil
+	}
+
+	depth, ok := coordsDepth[g.Type]
+	if !ok {
+		return fmt.Errorf("unknown geometry type %q", g.Type)
+	}
+	// Numbers are kept as written, so no precision is lost.
+	var coords []any
+	dec := json.NewDecoder(bytes.NewReader(g.Coordinates))
+	dec.UseNumber()
+	if len(g.Coordinates) > 0 {
+		if err := dec.Decode(&coords); err != nil {
+			return fmt.Errorf("invalid %s coordinates: %v", g.Type, err)
+		}
+	}
+
+	sb.WriteString(strings.ToUpper(g.Type))
+	if len(coords) == 0 {
+		sb.WriteString(" EMPTY")
+		return nil
+	}
+	if depth == 0 {
+		sb.WriteByte('(')
+		defer sb.WriteByte(')')
+	}
+	return writeCoords(sb, coords, depth)
+}
+
+// writeCoords writes positions nested depth levels deep, like the rings of a polygon at depth 2.
+func writeCoords(sb *strings.Builder, coords []any, depth int) error {
+	if depth == 0 {
+		if len(coords) < 2 {
+			return fmt.Errorf("position %v needs at least two coordinates", coords)
+		}
+		for i, c := range coords {
+			n, ok := c.(json.Number)
+			if !ok {
+				return fmt.Errorf("invalid coordinate %v", c)
+			}
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(n.String())
+		}
+		return nil
+	}
+
+	// Only the geometry as a whole can be EMPTY in WKT, not one of its rings or parts.
+	if len(coords) == 0 {
+		return fmt.Errorf("empty coordinates inside a geometry")
+	}
+	sb.WriteByte('(')
+	for i, c := range coords {
+		inner, ok := c.([]any)
+		if !ok {
+			return fmt.Errorf("invalid coordinates %v", c)
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if err := writeCoords(sb, inner, depth-1); err != nil {
+			return err
+		}
+	}
+	sb.WriteByte(')')
+	return nil
+}
+
+// GeoJSONSRID returns the SRID given by the `crs` member of older GeoJSON files, when it comes
+// before the features, or DefaultSRID. Only the start of the document is read.
+func GeoJSONSRID(r io.Reader) (int, error) {
+	iter := jsoniter.Parse(jsoniter.ConfigDefault, r, peekSize)
+	if iter.WhatIsNext() != jsoniter.ObjectValue {
+		return DefaultSRID, nil
+	}
+
+	var err error
+	srid := DefaultSRID
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "crs":
+			var crs struct {
+				Properties struct {
+					Name string `json:"name"`
+				} `json:"properties"`
+			}
+			if err = json.Unmarshal(iter.SkipAndReturnBytes(), &crs); err == nil {
+				srid, err = parseCRS(crs.Properties.Name)
+			}
+			return false
+		case "features":
+			return false
+		}
+		iter.Skip()
+		return true
+	})
+	return srid, err
+}
+
+// parseCRS reads the SRID from crs names like `EPSG:3857` or `urn:ogc:def:crs:EPSG::3857`.
+func parseCRS(name string) (int, error) {
+	if strings.HasSuffix(name, "CRS84") || name == "" {
+		return DefaultSRID, nil
+	}
+	m := epsgRe.FindStringSubmatch(name)
+	if m == nil {
+		return 0, fmt.Errorf("unsupported crs %q, only EPSG codes are supported", name)
+	}
+	return strconv.Atoi(m[1])
+}
@@ -0,0 +1,196 @@
+package reader
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteWKT(t *testing.T) {
+	tests := []struct {
+		name string
+		geom string
+		want string
+	}{
+		{"point", `{"type":"Point","coordinates":[1,2]}`, "POINT(1 2)"},
+		{"point with z", `{"type":"Point","coordinates":[1.5,-2,30]}`, "POINT(1.5 -2 30)"},
+		{"precision kept", `{"type":"Point","coordinates":[12.345678901234567890,1e-7]}`, "POINT(12.345678901234567890 1e-7)"},
+		{"multipoint", `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`, "MULTIPOINT(1 2, 3 4)"},
+		{"linestring", `{"type":"LineString","coordinates":[[0,0],[1,1],[2,0]]}`, "LINESTRING(0 0, 1 1, 2 0)"},
+		{
+			"polygon with a hole",
+			`{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]],[[1,1],[2,1],[2,2],[1,1]]]}`,
+			"POLYGON((0 0, 4 0, 4 4, 0 0), (1 1, 2 1, 2 2, 1 1))",
+		},
+		{"multilinestring", `{"type":"MultiLineString","coordinates":[[[0,0],[1,1]],[[2,2],[3,3]]]}`, "MULTILINESTRING((0 0, 1 1), (2 2, 3 3))"},
+		{
+			"multipolygon",
+			`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}`,
+			"MULTIPOLYGON(((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))",
+		},
+		{
+			"geometry collection",
+			`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[0,0],[1,1]]}]}`,
+			"GEOMETRYCOLLECTION(POINT(1 2), LINESTRING(0 0, 1 1))",
+		},
+		{
+			"nested geometry collection",
+			`{"type":"GeometryCollection","geometries":[{"type":"GeometryCollection","geometries":[]},{"type":"Point","coordinates":[]}]}`,
+			"GEOMETRYCOLLECTION(GEOMETRYCOLLECTION EMPTY, POINT EMPTY)",
+		},
+		{"empty point", `{"type":"Point","coordinates":[]}`, "POINT EMPTY"},
+		{"empty polygon", `{"type":"Polygon","coordinates":[]}`, "POLYGON EMPTY"},
+		{"null coordinates", `{"type":"LineString","coordinates":null}`, "LINESTRING EMPTY"},
+		{"missing coordinates", `{"type":"MultiPolygon"}`, "MULTIPOLYGON EMPTY"},
+		{"empty collection", `{"type":"GeometryCollection","geometries":[]}`, "GEOMETRYCOLLECTION EMPTY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := writeWKT(&sb, []byte(tt.geom)); err != nil {
+				t.Fatal(err)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteWKTInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		geom string
+	}{
+		{"not json", `{"type":`},
+		{"unknown type", `{"type":"Circle","coordinates":[1,2]}`},
+		{"lowercase type", `{"type":"point","coordinates":[1,2]}`},
+		{"one coordinate", `{"type":"Point","coordinates":[1]}`},
+		{"string coordinate", `{"type":"Point","coordinates":["1","2"]}`},
+		{"position instead of a list", `{"type":"LineString","coordinates":[1,2]}`},
+		{"list instead of a position", `{"type":"Point","coordinates":[[1,2]]}`},
+		{"empty ring", `{"type":"Polygon","coordinates":[[]]}`},
+		{"empty part", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[]]}`},
+		{"coordinates not an array", `{"type":"Point","coordinates":{"x":1}}`},
+		{"invalid geometry in a collection", `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := writeWKT(&sb, []byte(tt.geom)); err == nil {
+				t.Errorf("got %q, want an error", sb.String())
+			}
+		})
+	}
+}
+
+func TestGeoJSONReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		srid  int
+		want  string
+	}{
+		{
+			name: "feature collection as ewkt",
+			input: `{"type":"FeatureCollection","features":[
+				{"type":"Feature","id":7,"properties":{"name":"a&b"},"geometry":{"type":"Point","coordinates":[1,2]}},
+				{"type":"Feature","properties":{"id":"own"},"geometry":null}
+			]}`,
+			srid: 4326,
+			want: `{"geometry":"SRID=4326;POINT(1 2)","id":7,"name":"a&b"}` + "\n" +
+				`{"geometry":null,"id":"own"}` + "\n",
+		},
+		{
+			name:  "geometry kept as geojson without srid",
+			input: `{"type":"FeatureCollection","features":[{"type":"Feature","properties":null,"geometry":{"type":"Point","coordinates":[1.50,2]}}]}`,
+			want:  `{"geometry":{"type":"Point","coordinates":[1.50,2]}}` + "\n",
+		},
+		{
+			name:  "single feature",
+			input: `{"geometry":{"type":"Point","coordinates":[3,4]},"properties":{"n":1},"type":"Feature"}`,
+			srid:  3857,
+			want:  `{"geometry":"SRID=3857;POINT(3 4)","n":1}` + "\n",
+		},
+		{
+			name:  "empty collection",
+			input: `{"type":"FeatureCollection","features":[]}`,
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewGeoJSONReader(io.NopCloser(strings.NewReader(tt.input)), tt.srid)
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeoJSONReaderInvalid(t *testing.T) {
+	tests := []string{
+		`[{"type":"Feature"}]`,
+		`{"type":"Point","coordinates":[1,2]}`,
+		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[1]}}]}`,
+	}
+	for _, input := range tests {
+		r := NewGeoJSONReader(io.NopCloser(strings.NewReader(input)), DefaultSRID)
+		if got, err := io.ReadAll(r); err == nil {
+			t.Errorf("%s: got %q, want an error", input, got)
+		}
+		r.Close()
+	}
+}
+
+func TestParseCRS(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantErr bool
+	}{
+		{"", DefaultSRID, false},
+		{"urn:ogc:def:crs:OGC:1.3:CRS84", DefaultSRID, false},
+		{"EPSG:3857", 3857, false},
+		{"urn:ogc:def:crs:EPSG::27700", 27700, false},
+		{"urn:ogc:def:crs:EPSG:6.6:4326", 0, true},
+		{"ESRI:102100", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCRS(tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("got %d, %v, want %d, error %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGeoJSONSRID(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"crs before the features", `{"type":"FeatureCollection","crs":{"type":"name","properties":{"name":"EPSG:3857"}},"features":[]}`, 3857},
+		{"crs after the features", `{"type":"FeatureCollection","features":[],"crs":{"type":"name","properties":{"name":"EPSG:3857"}}}`, DefaultSRID},
+		{"no crs", `{"type":"FeatureCollection","features":[]}`, DefaultSRID},
+		{"not an object", `[]`, DefaultSRID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GeoJSONSRID(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
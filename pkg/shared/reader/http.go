@@ -0,0 +1,216 @@
+package reader
+
+import (
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+// Times an interrupted download is resumed with a range request before it fails.
+const maxResumes = 5
+
+// Content-Encodings asked for. The transport doesn't decode gzip on its own once this is set,
+// which keeps the byte offsets of range requests on the encoded body.
+const acceptEncoding = "gzip, zstd, br"
+
+// Longest wait for the next bytes of a body. A body that stalls for longer counts as a broken
+// connection, and is resumed like one.
+const idleTimeout = time.Minute
+
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	},
+}
+
+// httpBody is the body of a GET, which resumes where it stopped when the connection breaks.
+type httpBody struct {
+	// Stops the requests of the body, and its resumes, once it's done.
+	ctx  context.Context
+	url  string
+	body io.ReadCloser
+	// Cancels the request of body, which idle does when the body stalls.
+	cancel  context.CancelFunc
+	idle    *time.Timer
+	stalled atomic.Bool
+	// Bytes of the body read so far, where a resumed request starts.
+	off int64
+	// Whether the server takes range requests, and the ETag or Last-Modified sent with them
+	// as If-Range, so a file that changed isn't resumed with the bytes of the new version.
+	resumable bool
+	validator string
+	resumes   int
+}
+
+// openURL streams an http(s) URL. The Content-Encoding of the response is decoded first, and
+// then the data is decompressed when it starts with the magic bytes of a codec, like files
+// such as .csv.gz are.
+func openURL(ctx context.Context, url string) (io.ReadCloser, error) {
+	b := &httpBody{ctx: ctx, url: url}
+	resp, err := b.do(nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength > 0 {
+		shared.RecordFileSize(url, resp.ContentLength)
+	}
+
+	b.resumable = resp.Header.Get("Accept-Ranges") == "bytes"
+	b.validator = resp.Header.Get("ETag")
+	if b.validator == "" || strings.HasPrefix(b.validator, "W/") {
+		// If-Range only takes strong ETags.
+		b.validator = resp.Header.Get("Last-Modified")
+	}
+
+	decoded, err := decodeContent(b, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("GET %s: %w", url, err)
+	}
+	return decompress(decoded, decoded)
+}
+
+// do sends the GET of the body, whose request the idle timer cancels once it's armed.
+func (b *httpBody) do(header http.Header) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(b.ctx)
+	resp, err := get(ctx, b.url, header)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	b.body, b.cancel = resp.Body, cancel
+	b.idle = time.AfterFunc(idleTimeout, func() {
+		b.stalled.Store(true)
+		cancel()
+	})
+	b.idle.Stop()
+	return resp, nil
+}
+
+func get(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	req.Header.Set("User-Agent", "pgload")
+	return httpClient.Do(req)
+}
+
+// decodeContent returns a reader of the body with its Content-Encoding removed.
+func decodeContent(b *httpBody, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return b, nil
+	case "gzip", "x-gzip":
+		gr, err := pgzip.NewReader(b)
+		if err != nil {
+			return nil, err
+		}
+		return &closingReader{Reader: gr, closers: []io.Closer{gr, b}}, nil
+	case "zstd":
+		zr, err := zstd.NewReader(b)
+		if err != nil {
+			return nil, err
+		}
+		return &closingReader{Reader: zr, closers: []io.Closer{zr.IOReadCloser(), b}}, nil
+	case "br":
+		return &closingReader{Reader: brotli.NewReader(b), closers: []io.Closer{b}}, nil
+	case "deflate":
+		zr, err := zlib.NewReader(b)
+		if err != nil {
+			return nil, err
+		}
+		return &closingReader{Reader: zr, closers: []io.Closer{zr, b}}, nil
+	}
+	return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+}
+
+func (b *httpBody) Read(p []byte) (int, error) {
+	for {
+		// Only the wait inside Read counts, not the time the loader takes between reads.
+		b.idle.Reset(idleTimeout)
+		n, err := b.body.Read(p)
+		b.idle.Stop()
+		b.off += int64(n)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if b.stalled.Swap(false) {
+			err = fmt.Errorf("no data received for %s", idleTimeout)
+		} else if b.ctx.Err() != nil {
+			return n, fmt.Errorf("GET %s: %w", b.url, b.ctx.Err())
+		}
+		if rerr := b.resume(err); rerr != nil {
+			return n, rerr
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume requests the rest of the body after the connection broke with cause. It gives up
+// as soon as the ctx of the body is done.
+func (b *httpBody) resume(cause error) error {
+	b.Close()
+	for b.resumable && b.resumes < maxResumes {
+		b.resumes++
+		wait := time.NewTimer(time.Duration(b.resumes) * time.Second)
+		select {
+		case <-b.ctx.Done():
+			wait.Stop()
+			return fmt.Errorf("GET %s: %w", b.url, b.ctx.Err())
+		case <-wait.C:
+		}
+
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", b.off)}}
+		if b.validator != "" {
+			header.Set("If-Range", b.validator)
+		}
+		resp, err := b.do(header)
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return fmt.Errorf("GET %s: %w", b.url, b.ctx.Err())
+			}
+			cause = err
+			continue
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			b.Close()
+			// A 200 means the file changed since the download started, or the range was ignored.
+			return fmt.Errorf("GET %s: can't resume at byte %d: %s", b.url, b.off, resp.Status)
+		}
+		return nil
+	}
+	return fmt.Errorf("GET %s: %w", b.url, cause)
+}
+
+func (b *httpBody) Close() error {
+	b.idle.Stop()
+	defer b.cancel()
+	return b.body.Close()
+}
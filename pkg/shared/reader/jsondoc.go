@@ -0,0 +1,195 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const peekSize = 64 * 1024
+
+// A single JSON path step. An empty key, from [*] or .*, means every element of an array
+// or every value of an object.
+type pathStep struct {
+	key string
+}
+
+type rowWriter struct {
+	w   *bufio.Writer
+	buf bytes.Buffer
+}
+
+// NewJSONDocumentReader streams the objects found at path inside a JSON document as JSONL,
+// so they can go through the same pipeline as JSONL files. The path supports keys and
+// wildcards over array elements or object values, like `$.data.items[*]` or `$.data.*`. When path is empty, every top-level value is used:
+// arrays give one row per element and objects give a single row.
+func NewJSONDocumentReader(src io.ReadCloser, path string) (io.ReadCloser, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return newConvertingReader(src, func(w *bufio.Writer) error {
+		return streamJSONDocument(src, steps, &rowWriter{w: w})
+	}), nil
+}
+
+// IsJSONLines peeks at the start of the data to tell JSONL apart from a JSON document,
+// which is either a top-level array or an object spread over several lines.
+func IsJSONLines(br *bufio.Reader) bool {
+	data, _ := br.Peek(peekSize)
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 || data[0] != '{' {
+		return len(data) == 0
+	}
+	idx := bytes.IndexByte(data, '\n')
+	if idx == -1 {
+		// Either the whole file is a single line or the first line is longer than what we peeked,
+		// neither of which happens with pretty-printed JSON.
+		return true
+	}
+	return json.Valid(data[:idx])
+}
+
+func parseJSONPath(path string) ([]pathStep, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, nil
+	}
+	if path[0] != '$' {
+		return nil, fmt.Errorf("invalid json path %q: must start with $", path)
+	}
+
+	var steps []pathStep
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "[*]"):
+			steps = append(steps, pathStep{})
+			rest = rest[3:]
+		case strings.HasPrefix(rest, ".*"):
+			steps = append(steps, pathStep{})
+			rest = rest[2:]
+		case strings.HasPrefix(rest, `["`), strings.HasPrefix(rest, `['`):
+			end := strings.Index(rest[2:], rest[1:2]+"]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid json path %q: unterminated key", path)
+			}
+			steps = append(steps, pathStep{key: rest[2 : 2+end]})
+			rest = rest[2+end+2:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid json path %q: empty key", path)
+			}
+			steps = append(steps, pathStep{key: rest[1 : 1+end]})
+			rest = rest[1+end:]
+		default:
+			return nil, fmt.Errorf("invalid json path %q: unexpected %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+func streamJSONDocument(r io.Reader, steps []pathStep, rw *rowWriter) error {
+	iter := jsoniter.Parse(jsoniter.ConfigDefault, r, peekSize)
+	// A file can hold more than one top-level value, like concatenated pretty-printed objects.
+	for iter.WhatIsNext() != jsoniter.InvalidValue {
+		if err := walk(iter, steps, rw); err != nil {
+			return err
+		}
+	}
+	if iter.Error != io.EOF {
+		return iter.Error
+	}
+	return nil
+}
+
+// walk follows the path steps from the value the iterator is positioned at.
+// Values that don't match the path are skipped without being decoded.
+func walk(iter *jsoniter.Iterator, steps []pathStep, rw *rowWriter) error {
+	if len(steps) == 0 {
+		return writeRows(iter, rw)
+	}
+
+	var err error
+	step := steps[0]
+	switch {
+	case step.key == "" && iter.WhatIsNext() == jsoniter.ArrayValue:
+		iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+			err = walk(iter, steps[1:], rw)
+			return err == nil
+		})
+	case iter.WhatIsNext() == jsoniter.ObjectValue:
+		iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+			if step.key != "" && field != step.key {
+				iter.Skip()
+				return true
+			}
+			err = walk(iter, steps[1:], rw)
+			return err == nil
+		})
+	default:
+		iter.Skip()
+	}
+	if err != nil {
+		return err
+	}
+	return iterError(iter)
+}
+
+// writeRows writes the value the iterator is positioned at as JSONL rows,
+// one per element for arrays.
+func writeRows(iter *jsoniter.Iterator, rw *rowWriter) error {
+	if iter.WhatIsNext() != jsoniter.ArrayValue {
+		return writeRow(iter, rw)
+	}
+	var err error
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		err = writeRow(iter, rw)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return iterError(iter)
+}
+
+func writeRow(iter *jsoniter.Iterator, rw *rowWriter) error {
+	switch iter.WhatIsNext() {
+	case jsoniter.NilValue:
+		iter.Skip()
+		return iterError(iter)
+	case jsoniter.ObjectValue:
+	default:
+		return fmt.Errorf("expected a JSON object for each row, got %.40s", iter.SkipAndReturnBytes())
+	}
+
+	raw := iter.SkipAndReturnBytes()
+	if err := iterError(iter); err != nil {
+		return err
+	}
+	// Pretty-printed objects span lines, while JSONL needs one object per line.
+	rw.buf.Reset()
+	if err := json.Compact(&rw.buf, raw); err != nil {
+		return err
+	}
+	rw.buf.WriteByte('\n')
+	_, err := rw.w.Write(rw.buf.Bytes())
+	return err
+}
+
+// iterError ignores io.EOF as reaching the end of the input is checked separately.
+func iterError(iter *jsoniter.Iterator) error {
+	if iter.Error == io.EOF {
+		return nil
+	}
+	return iter.Error
+}
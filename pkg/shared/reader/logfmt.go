@@ -0,0 +1,111 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// NewLogfmtReader streams logfmt lines, like `level=info msg="user created" id=42`, as JSONL
+// with one object per line. Keys without a value are set to true, and lines without
+// any key=value pair are skipped.
+func NewLogfmtReader(src io.ReadCloser) io.ReadCloser {
+	return newConvertingReader(src, func(w *bufio.Writer) error {
+		return streamLogfmt(src, w)
+	})
+}
+
+func streamLogfmt(r io.Reader, w *bufio.Writer) error {
+	br := bufio.NewReaderSize(r, peekSize)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if rec := parseLogfmt(bytes.TrimRight(line, "\r\n")); rec != nil {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseLogfmt is lenient: malformed pairs are read as well as possible instead of failing the file.
+func parseLogfmt(line []byte) map[string]any {
+	rec := map[string]any{}
+	pairs := 0
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		key := string(line[start:i])
+		if i == len(line) || line[i] != '=' {
+			if key != "" {
+				rec[key] = true
+			}
+			continue
+		}
+		i++ // skip '='
+
+		var val string
+		if i < len(line) && line[i] == '"' {
+			val, i = readQuoted(line, i)
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			val = string(line[start:i])
+		}
+		if key == "" {
+			continue
+		}
+		pairs++
+		if val == "" {
+			rec[key] = nil
+			continue
+		}
+		rec[key] = textValue(val)
+	}
+	if pairs == 0 {
+		return nil
+	}
+	return rec
+}
+
+// readQuoted reads the quoted value starting at line[i] and returns it unescaped,
+// along with the index after the closing quote.
+func readQuoted(line []byte, i int) (string, int) {
+	end := i + 1
+	for end < len(line) && line[end] != '"' {
+		if line[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(line) {
+		// No closing quote, take the rest of the line.
+		return string(line[i+1:]), len(line)
+	}
+	quoted := string(line[i : end+1])
+	if val, err := strconv.Unquote(quoted); err == nil {
+		return val, end + 1
+	}
+	return quoted[1 : len(quoted)-1], end + 1
+}
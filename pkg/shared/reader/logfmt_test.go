@@ -0,0 +1,108 @@
+package reader
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want map[string]any
+	}{
+		{
+			name: "pairs",
+			line: `level=info msg="user created" id=42`,
+			want: map[string]any{"level": "info", "msg": "user created", "id": json.Number("42")},
+		},
+		{
+			name: "numbers",
+			line: `a=-1.5 b=1e3 zip=02134 v=1.2.3`,
+			want: map[string]any{"a": json.Number("-1.5"), "b": json.Number("1e3"), "zip": "02134", "v": "1.2.3"},
+		},
+		{
+			name: "bare keys",
+			line: `debug level=warn verbose`,
+			want: map[string]any{"debug": true, "level": "warn", "verbose": true},
+		},
+		{
+			name: "empty values",
+			line: `a= b="" c=1`,
+			want: map[string]any{"a": nil, "b": nil, "c": json.Number("1")},
+		},
+		{
+			name: "escaped quotes",
+			line: `msg="say \"hi\"" path="C:\\tmp"`,
+			want: map[string]any{"msg": `say "hi"`, "path": `C:\tmp`},
+		},
+		{
+			name: "invalid escape kept as written",
+			line: `path="C:\xyz" n=1`,
+			want: map[string]any{"path": `C:\xyz`, "n": json.Number("1")},
+		},
+		{
+			name: "unterminated quote takes the rest of the line",
+			line: `a=1 msg="no end b=2`,
+			want: map[string]any{"a": json.Number("1"), "msg": "no end b=2"},
+		},
+		{
+			name: "quoted value with spaces and equals",
+			line: `q="a=b c=d" x=y`,
+			want: map[string]any{"q": "a=b c=d", "x": "y"},
+		},
+		{
+			name: "equals in unquoted value",
+			line: `url=http://h/?a=b`,
+			want: map[string]any{"url": "http://h/?a=b"},
+		},
+		{
+			name: "tabs and repeated spaces",
+			line: "\ta=1  \t b=2 ",
+			want: map[string]any{"a": json.Number("1"), "b": json.Number("2")},
+		},
+		{
+			name: "last duplicate wins",
+			line: `a=1 a=2`,
+			want: map[string]any{"a": json.Number("2")},
+		},
+		{
+			name: "empty key skipped",
+			line: `=x a=1`,
+			want: map[string]any{"a": json.Number("1")},
+		},
+		{
+			name: "unicode",
+			line: `city=Zürich msg="héllo wörld"`,
+			want: map[string]any{"city": "Zürich", "msg": "héllo wörld"},
+		},
+		{name: "only words", line: `just some words`, want: nil},
+		{name: "only an empty key", line: `=x`, want: nil},
+		{name: "empty line", line: ``, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseLogfmt([]byte(tt.line)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogfmtReader(t *testing.T) {
+	input := "a=1 b=x\r\n\nnot a pair\nmsg=\"<b>&\"\nlast=true"
+	r := NewLogfmtReader(io.NopCloser(strings.NewReader(input)))
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":1,"b":"x"}` + "\n" + `{"msg":"<b>&"}` + "\n" + `{"last":"true"}` + "\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -1,48 +1,131 @@
 package reader
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"context"
 	"errors"
 	"io"
 	"os"
 
 	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Magic bytes at the start of the compressed files.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	lz4Magic   = []byte{0x04, 0x22, 0x4d, 0x18}
+)
+
+// Read ahead of gzip inputs: up to 8 blocks of 1MB are decompressed before they are read.
+const (
+	gzipBlockSize = 1 << 20
+	gzipBlocks    = 8
 )
 
 type FileGzipReader struct {
-	actualReader *os.File
-	gzReader     *gzip.Reader
+	actualReader io.Closer
+	// Decompresses the file, or reads it as is when it isn't compressed.
+	reader       io.Reader
+	decompressor io.Closer
 }
 
 // Return a reader that internally handles both compressed and uncompressed files.
+// The codec, gzip, zstd, bzip2, xz or lz4, comes from the magic bytes of the file,
+// so compressed files don't need a matching suffix. Archive member paths, like
+// bundle.zip::orders.csv, are read straight from the archive, http(s) and s3:// URLs are
+// streamed and "-" reads stdin.
 func NewFileGzipReader(file string) (io.ReadCloser, error) {
+	return NewFileGzipReaderContext(context.Background(), file)
+}
+
+// NewFileGzipReaderContext is NewFileGzipReader with the downloads of http(s) and s3:// URLs
+// stopped, and no longer retried, once ctx is done.
+func NewFileGzipReaderContext(ctx context.Context, file string) (io.ReadCloser, error) {
+	if file == shared.Stdin {
+		return openStdin()
+	}
+	if shared.IsURL(file) {
+		return openURL(ctx, file)
+	}
+	if shared.IsS3URL(file) {
+		return openS3(ctx, file)
+	}
+	if archive, member, ok := shared.SplitArchivePath(file); ok {
+		return openMember(ctx, archive, member)
+	}
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
-	fzr := &FileGzipReader{actualReader: f}
-	if shared.IsGZIPFile(file) {
-		gr, err := gzip.NewReader(f)
+	return decompress(f, f)
+}
+
+// decompress returns a reader that decompresses src when it starts with the magic bytes
+// of a codec. Closing it closes closer, which is also closed when it fails.
+func decompress(src io.Reader, closer io.Closer) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(src, 64*1024)
+	// Inputs shorter than the longest magic are read as they are.
+	magic, _ := br.Peek(len(xzMagic))
+
+	fzr := &FileGzipReader{actualReader: closer, reader: br}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		// Decompresses ahead of the reader on its own goroutine, in gzipBlocks blocks of
+		// gzipBlockSize, so the decompression runs alongside the parsing and COPY of the
+		// data already read instead of taking turns with them on one core.
+		gr, err := pgzip.NewReaderN(br, gzipBlockSize, gzipBlocks)
+		if err != nil {
+			closer.Close()
+			return nil, err
+		}
+		fzr.reader, fzr.decompressor = gr, gr
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
 		if err != nil {
+			closer.Close()
 			return nil, err
 		}
-		fzr.gzReader = gr
+		fzr.reader, fzr.decompressor = zr, zr.IOReadCloser()
+	case isBzip2(magic):
+		fzr.reader = bzip2.NewReader(br)
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			closer.Close()
+			return nil, err
+		}
+		fzr.reader = xr
+	case bytes.HasPrefix(magic, lz4Magic):
+		fzr.reader = lz4.NewReader(br)
 	}
 	return fzr, nil
 }
 
+// isBzip2 checks for "BZh" followed by the block size, '1' to '9', which keeps text
+// files that happen to start with "BZh" from being taken for bzip2.
+func isBzip2(magic []byte) bool {
+	return len(magic) > len(bzip2Magic) && bytes.HasPrefix(magic, bzip2Magic) &&
+		magic[len(bzip2Magic)] >= '1' && magic[len(bzip2Magic)] <= '9'
+}
+
 func (r *FileGzipReader) Read(p []byte) (int, error) {
-	if r.gzReader != nil {
-		return r.gzReader.Read(p)
-	}
-	return r.actualReader.Read(p)
+	return r.reader.Read(p)
 }
 
-// Close both the GZIP reader and the actual file reader.
+// Close both the decompressor and the actual file reader.
 func (r *FileGzipReader) Close() error {
 	var err error
-	if r.gzReader != nil {
-		err = r.gzReader.Close()
+	if r.decompressor != nil {
+		err = r.decompressor.Close()
 	}
 	return errors.Join(err, r.actualReader.Close())
 }
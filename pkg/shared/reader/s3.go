@@ -0,0 +1,125 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/anvesh9652/pgload/pkg/shared"
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Endpoint used when none is set in the environment.
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+// s3Client connects to the endpoint in AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL, like
+// http://localhost:9000 for a local MinIO, or to AWS S3. The credentials come from the
+// AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY or MINIO_ROOT_USER/MINIO_ROOT_PASSWORD variables,
+// the AWS credentials file (AWS_PROFILE) or the instance role, in that order.
+var s3Client = sync.OnceValues(func() (*minio.Client, error) {
+	endpoint, secure := defaultS3Endpoint, true
+	if env := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); env != "" {
+		secure = !strings.HasPrefix(env, "http://")
+		endpoint = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(env, "http://"), "https://"), "/")
+	}
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+	return minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+		Region: firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+	})
+})
+
+// firstEnv returns the value of the first of the environment variables that is set.
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if val := os.Getenv(key); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// ListS3 returns the s3:// URLs of the objects a pattern like s3://bucket/daily/*.csv.gz
+// matches. Only the keys under the part of the pattern before its first wildcard are
+// listed, and they are matched like local glob patterns, so * doesn't match a / and **
+// matches any number of directories.
+// A pattern ending in / matches every object under that prefix, and one without
+// wildcards is the object itself.
+func ListS3(pattern string) ([]string, error) {
+	bucket, keyPattern := shared.SplitS3URL(pattern)
+	if bucket == "" {
+		return nil, fmt.Errorf("%s has no bucket", pattern)
+	}
+	wildcard := strings.IndexAny(keyPattern, "*?[{")
+	if wildcard < 0 && keyPattern != "" && !strings.HasSuffix(keyPattern, "/") {
+		return []string{pattern}, nil
+	}
+	if !doublestar.ValidatePattern(keyPattern) {
+		return nil, fmt.Errorf("invalid pattern %s", pattern)
+	}
+
+	client, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+	prefix := keyPattern
+	if wildcard >= 0 {
+		prefix = keyPattern[:wildcard]
+	}
+
+	var files []string
+	objects := client.ListObjects(context.Background(), bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for obj := range objects {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("listing %s: %w", pattern, obj.Err)
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			// Folder markers of the S3 consoles.
+			continue
+		}
+		if wildcard >= 0 {
+			if !doublestar.MatchUnvalidated(keyPattern, obj.Key) {
+				continue
+			}
+		}
+		file := "s3://" + bucket + "/" + obj.Key
+		shared.RecordFileSize(file, obj.Size)
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no objects match %s", pattern)
+	}
+	return files, nil
+}
+
+// openS3 streams an object, decompressing it like a file when it's compressed.
+func openS3(ctx context.Context, file string) (io.ReadCloser, error) {
+	client, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key := shared.SplitS3URL(file)
+	obj, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// Fails early for missing objects, which GetObject leaves to the first read.
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	shared.RecordFileSize(file, info.Size)
+	return decompress(obj, obj)
+}
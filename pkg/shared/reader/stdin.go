@@ -0,0 +1,73 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Most of stdin, or of a streamed tar member, that is kept in memory. Loaders open their
+// input more than once, first to infer the columns from a sample and then for COPY, so every
+// reader of a stream starts with what the earlier ones read. Once a reader goes past this
+// much, the kept data is dropped and that reader alone goes on with the rest of the stream.
+const replayKeepLimit = 64 << 20
+
+var errStdinConsumed = errors.New("stdin was already read past the data kept for type inference; use a smaller --lookup")
+
+// replaySource records what is read from a stream, so it can be replayed to the next reader.
+type replaySource struct {
+	mu      sync.Mutex
+	src     io.Reader
+	kept    []byte
+	dropped bool
+	// Returned to the readers that come after the kept data was dropped.
+	consumed error
+}
+
+var stdin = &replaySource{src: os.Stdin, consumed: errStdinConsumed}
+
+// replayReader replays the data kept from a stream, then reads on from the stream.
+type replayReader struct {
+	s    *replaySource
+	off  int
+	live bool
+}
+
+// openStdin returns a reader of stdin that starts from its beginning, as long as the earlier
+// readers didn't go past replayKeepLimit. Compressed input is decompressed like files are.
+func openStdin() (io.ReadCloser, error) {
+	return openReplay(stdin)
+}
+
+func openReplay(s *replaySource) (io.ReadCloser, error) {
+	r := &replayReader{s: s}
+	return decompress(r, io.NopCloser(r))
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	s := r.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.live {
+		return s.src.Read(p)
+	}
+	if s.dropped {
+		return 0, s.consumed
+	}
+	if r.off < len(s.kept) {
+		n := copy(p, s.kept[r.off:])
+		r.off += n
+		return n, nil
+	}
+
+	n, err := s.src.Read(p)
+	if len(s.kept)+n > replayKeepLimit {
+		s.kept, s.dropped, r.live = nil, true, true
+		return n, err
+	}
+	s.kept = append(s.kept, p[:n]...)
+	r.off += n
+	return n, err
+}
@@ -0,0 +1,108 @@
+package reader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Tail reads the lines of a file that is still being written, like tail -F. It goes on with
+// the new file when the file is rotated, that is renamed and created again, and starts over
+// when it's truncated in place.
+type Tail struct {
+	path string
+	f    *os.File
+	br   *bufio.Reader
+	// Bytes of the file read so far, including the partial line.
+	off int64
+	// Start of the last line, whose newline isn't written yet.
+	partial []byte
+	// The next line is the first one of the file, which is new after a rotation.
+	fresh bool
+}
+
+func OpenTail(path string) (*Tail, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Tail{path: path, f: f, br: bufio.NewReaderSize(f, 64*1024), fresh: true}, nil
+}
+
+// Next returns the next complete line with its newline, and whether it's the first line of
+// the file, like a header row. It returns a nil line once it caught up with the writer.
+func (t *Tail) Next() (line []byte, first bool, err error) {
+	for {
+		b, err := t.br.ReadBytes('\n')
+		t.off += int64(len(b))
+		if err == nil {
+			line, t.partial = append(t.partial, b...), nil
+			first, t.fresh = t.fresh, false
+			return line, first, nil
+		}
+		if err != io.EOF {
+			return nil, false, err
+		}
+		t.partial = append(t.partial, b...)
+
+		rotated, more, err := t.check()
+		if err != nil || !more {
+			return nil, false, err
+		}
+		if rotated && len(t.partial) > 0 {
+			// The old file ended without a newline, which the writer won't add anymore.
+			line, t.partial = append(t.partial, '\n'), nil
+			first, t.fresh = t.fresh, true
+			return line, first, nil
+		}
+	}
+}
+
+// check looks for data written after the end of the file was read, or for a new file in its
+// place, which is then opened. more tells whether there's something left to read.
+func (t *Tail) check() (rotated, more bool, err error) {
+	cur, err := t.f.Stat()
+	if err != nil {
+		return false, false, err
+	}
+	switch {
+	case cur.Size() > t.off:
+		// Written to after the last read; the old file is read to its end before a rotation.
+		return false, true, nil
+	case cur.Size() < t.off:
+		// Truncated in place, like copytruncate does.
+		if _, err := t.f.Seek(0, io.SeekStart); err != nil {
+			return false, false, err
+		}
+		t.br.Reset(t.f)
+		t.off, t.partial, t.fresh = 0, nil, true
+		return false, true, nil
+	}
+
+	info, err := os.Stat(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Renamed away, and the new file isn't created yet.
+		return false, false, nil
+	}
+	if err != nil || os.SameFile(info, cur) {
+		return false, false, err
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return false, false, err
+	}
+	t.f.Close()
+	t.f = f
+	t.br.Reset(f)
+	t.off = 0
+	if len(t.partial) == 0 {
+		t.fresh = true
+	}
+	return true, true, nil
+}
+
+func (t *Tail) Close() error {
+	return t.f.Close()
+}
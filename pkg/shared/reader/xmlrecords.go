@@ -0,0 +1,160 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Key of the text of elements that also have attributes or child elements.
+const xmlTextKey = "#text"
+
+// NewXMLRecordReader streams the elements found at recordPath, like `/catalog/item`, as JSONL
+// with one object per element. Attributes and child elements become keys; children that have
+// their own attributes or children, and repeated children, become nested JSON.
+// Only the element being converted is kept in memory. When recordPath is empty,
+// the children of the root element are used.
+func NewXMLRecordReader(src io.ReadCloser, recordPath string) (io.ReadCloser, error) {
+	path, err := parseRecordPath(recordPath)
+	if err != nil {
+		return nil, err
+	}
+	return newConvertingReader(src, func(w *bufio.Writer) error {
+		return streamXMLRecords(src, path, w)
+	}), nil
+}
+
+// parseRecordPath splits an absolute element path. A `*` step matches any element.
+func parseRecordPath(recordPath string) ([]string, error) {
+	recordPath = strings.TrimSpace(recordPath)
+	if recordPath == "" {
+		return []string{"*", "*"}, nil
+	}
+	if !strings.HasPrefix(recordPath, "/") {
+		return nil, fmt.Errorf("invalid record path %q: must start with /", recordPath)
+	}
+	steps := strings.Split(strings.TrimSuffix(recordPath[1:], "/"), "/")
+	if slices.Contains(steps, "") {
+		return nil, fmt.Errorf("invalid record path %q: empty element name", recordPath)
+	}
+	return steps, nil
+}
+
+func streamXMLRecords(r io.Reader, path []string, w *bufio.Writer) error {
+	dec := xml.NewDecoder(r)
+	// Lots of datasets are still published in encodings like ISO-8859-1.
+	dec.CharsetReader = charset.NewReaderLabel
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	var stack []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			if !matchesRecordPath(path, stack) {
+				continue
+			}
+			val, err := decodeElement(dec, t)
+			if err != nil {
+				return err
+			}
+			stack = stack[:len(stack)-1]
+
+			rec, ok := val.(map[string]any)
+			if !ok {
+				// A record with only text becomes a single column named after the element.
+				rec = map[string]any{t.Name.Local: val}
+			}
+			if err = enc.Encode(rec); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func matchesRecordPath(path, stack []string) bool {
+	if len(path) != len(stack) {
+		return false
+	}
+	for i, step := range path {
+		if step != "*" && step != stack[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeElement reads the element up to its end tag. Elements with only text give their value,
+// and empty elements give nil.
+func decodeElement(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	obj := map[string]any{}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		obj[attr.Name.Local] = textValue(attr.Value)
+	}
+
+	var text strings.Builder
+	children := map[string]int{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			// A child with the same name as an attribute replaces it.
+			name := t.Name.Local
+			switch children[name] {
+			case 0:
+				obj[name] = child
+			case 1:
+				obj[name] = []any{obj[name], child}
+			default:
+				obj[name] = append(obj[name].([]any), child)
+			}
+			children[name]++
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(obj) == 0 {
+				if s == "" {
+					return nil, nil
+				}
+				return textValue(s), nil
+			}
+			if s != "" {
+				obj[xmlTextKey] = textValue(s)
+			}
+			return obj, nil
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -20,15 +21,40 @@ var (
 
 // data formats
 var (
-	CSV   = "csv"
-	JSONL = "jsonl"
-	Both  = "both"
+	CSV      = "csv"
+	JSONL    = "jsonl"
+	Parquet  = "parquet"
+	Avro     = "avro"
+	Arrow    = "arrow"
+	XLSX     = "xlsx"
+	XML      = "xml"
+	SQLite   = "sqlite"
+	Logfmt   = "logfmt"
+	Pattern  = "pattern"
+	SQL      = "sql"
+	Protobuf = "protobuf"
+	GeoJSON  = "geojson"
+	Both     = "both"
 )
 
+// Stdin is the input path that reads the data from standard input.
+const Stdin = "-"
+
+// Table names given explicitly, like the --table of stdin input, by input path.
+var tableNames sync.Map
+
+// RecordTableName sets the table GetTableName returns for an input without a usable file name.
+func RecordTableName(path, table string) {
+	tableNames.Store(path, table)
+}
+
 func GetTableName(file string) string {
+	if table, ok := tableNames.Load(file); ok {
+		return GetObjectTableName(table.(string))
+	}
 	// Table names are being created with lowercase letters in pg
 	// even if we pass uppercase letters.
-	file = strings.ToLower(file)
+	file = strings.ToLower(InputName(file))
 	pathSplit := strings.Split(file, "/")
 	N := len(pathSplit)
 	// We are sure that we will always have a proper file name that can be either .csv or .json or .gz,
@@ -41,6 +67,30 @@ func GetTableName(file string) string {
 		// We can't have a table name that starts with a digit.
 		name = "t" + name
 	}
+	return sanitizeName(name)
+}
+
+// GetSheetTableName names the table of a single sheet inside a workbook file.
+func GetSheetTableName(file, sheet string) string {
+	return GetTableName(file) + "_" + sanitizeName(strings.ToLower(sheet))
+}
+
+// GetRouteTableName names the table of the records of a file routed by the given value.
+func GetRouteTableName(file, value string) string {
+	return GetTableName(file) + "_" + sanitizeName(strings.ToLower(value))
+}
+
+// GetObjectTableName names the table of an object inside a file, like a SQLite table,
+// after the object alone.
+func GetObjectTableName(object string) string {
+	name := sanitizeName(strings.ToLower(object))
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "t" + name
+	}
+	return name
+}
+
+func sanitizeName(name string) string {
 	var final string
 	for _, r := range name {
 		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
@@ -54,10 +104,10 @@ func GetTableName(file string) string {
 
 func getFileName(name string) string {
 	ns := strings.Split(name, ".")
-	if !IsGZIPFile(name) {
+	if !IsCompressedFile(name) {
 		return ns[0]
 	}
-	// file.csv.gz => file_gz
+	// file.csv.gz => file_gz, file.csv.zst => file_zst
 	return fmt.Sprintf("%s_%s", ns[0], ns[len(ns)-1])
 }
 
@@ -83,8 +133,22 @@ func PrettyPrintJson(data any, w io.Writer) {
 	w.Write(bytes)
 }
 
+// Sizes of the inputs that aren't files on disk, like archive members.
+var fileSizes sync.Map
+
+// RecordFileSize sets the size GetFileSize reports for an input that isn't a file on disk.
+func RecordFileSize(path string, size int64) {
+	fileSizes.Store(path, size)
+}
+
 func GetFileSize(path string) (res string) {
 	res = "unknown"
+	if path == Stdin {
+		return
+	}
+	if size, ok := fileSizes.Load(path); ok {
+		return strings.ReplaceAll(humanize.Bytes(uint64(size.(int64))), " ", "")
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return
@@ -146,17 +210,108 @@ func RunInParallel(numWorkers int, items []string, fn func(item string) error) e
 	return workerErr
 }
 
-func IsGZIPFile(name string) bool {
-	// Check if the file is a GZIP file.
-	return strings.HasSuffix(name, ".gz")
+// Suffixes of the compressed files the reader decompresses.
+var compressionSuffixes = []string{".gz", ".zst", ".bz2", ".xz", ".lz4"}
+
+// IsCompressedFile reports whether the file name ends in a known compression suffix.
+func IsCompressedFile(name string) bool {
+	return TrimCompressionSuffix(name) != name
+}
+
+// TrimCompressionSuffix removes a known compression suffix, like .gz or .zst, from the file name.
+func TrimCompressionSuffix(name string) string {
+	for _, suffix := range compressionSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// ArchiveSeparator joins an archive and the path of a member inside it, like bundle.zip::exports/orders.csv.
+const ArchiveSeparator = "::"
+
+// ArchiveMemberPath returns the path that loaders open the archive member through.
+func ArchiveMemberPath(archive, member string) string {
+	return archive + ArchiveSeparator + member
+}
+
+// SplitArchivePath splits an archive member path into the archive and the member path.
+func SplitArchivePath(file string) (archive, member string, ok bool) {
+	// The last separator is the one, since URLs like http://[::1]/a.csv have one too.
+	i := strings.LastIndex(file, ArchiveSeparator)
+	if i < 0 || !isArchiveName(InputName(file[:i])) {
+		return "", "", false
+	}
+	return file[:i], file[i+len(ArchiveSeparator):], true
+}
+
+// IsArchiveFile reports whether the file is a zip or tar archive, like .zip, .tar, .tgz or .tar.gz.
+func IsArchiveFile(name string) bool {
+	if _, _, ok := SplitArchivePath(name); ok {
+		// Archives inside archives aren't expanded.
+		return false
+	}
+	return isArchiveName(InputName(name))
+}
+
+func isArchiveName(name string) bool {
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tgz") ||
+		strings.HasSuffix(TrimCompressionSuffix(name), ".tar")
+}
+
+// IsURL reports whether the input is an http:// or https:// URL.
+func IsURL(file string) bool {
+	return strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://")
+}
+
+// IsS3URL reports whether the input is an s3://bucket/key object URL.
+func IsS3URL(file string) bool {
+	return strings.HasPrefix(file, "s3://")
+}
+
+// IsRemoteFile reports whether the input is streamed over the network, from an http(s) URL
+// or from object storage.
+func IsRemoteFile(file string) bool {
+	return IsURL(file) || IsS3URL(file)
+}
+
+// SplitS3URL splits an s3:// URL into its bucket and object key.
+func SplitS3URL(file string) (bucket, key string) {
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(file, "s3://"), "/")
+	return bucket, key
 }
 
+// InputName returns the name the format and the table of an input come from: the path
+// inside the archive for archive members, the path without the host and the query for
+// URLs, the object key for s3:// URLs, and the file path itself otherwise.
+func InputName(file string) string {
+	if _, member, ok := SplitArchivePath(file); ok {
+		return member
+	}
+	if IsS3URL(file) {
+		_, key := SplitS3URL(file)
+		return key
+	}
+	if IsURL(file) {
+		if u, err := url.Parse(file); err == nil {
+			return strings.TrimPrefix(u.Path, "/")
+		}
+	}
+	return file
+}
+
+// IsCSVFile reports whether the file is a delimited text file: .csv, .tsv or .psv, optionally compressed.
 func IsCSVFile(name string) bool {
-	if strings.HasSuffix(name, ".csv") {
+	ns := strings.Split(TrimCompressionSuffix(name), ".")
+	if len(ns) < 2 {
+		return false
+	}
+	switch ns[len(ns)-1] {
+	case "csv", "tsv", "psv":
 		return true
 	}
-	ns := strings.Split(name, ".")
-	return IsGZIPFile(name) && len(ns) >= 3 && ns[len(ns)-2] == "csv"
+	return false
 }
 
 func IsJSONFile(name string) bool {
@@ -164,5 +319,78 @@ func IsJSONFile(name string) bool {
 		return true
 	}
 	ns := strings.Split(name, ".")
-	return IsGZIPFile(name) && len(ns) > 2 && (ns[len(ns)-2] == "json" || ns[len(ns)-2] == "jsonl")
+	return IsCompressedFile(name) && len(ns) > 2 && (ns[len(ns)-2] == "json" || ns[len(ns)-2] == "jsonl")
+}
+
+func IsGeoJSONFile(name string) bool {
+	return strings.HasSuffix(TrimCompressionSuffix(name), ".geojson")
+}
+
+func IsParquetFile(name string) bool {
+	return strings.HasSuffix(name, ".parquet")
+}
+
+// IsArrowFile reports whether the file holds Arrow IPC data: Feather v2, IPC file or IPC stream.
+func IsArrowFile(name string) bool {
+	for _, ext := range []string{".arrow", ".feather", ".ipc", ".arrows"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func IsAvroFile(name string) bool {
+	if strings.HasSuffix(name, ".avro") {
+		return true
+	}
+	ns := strings.Split(name, ".")
+	return IsCompressedFile(name) && len(ns) >= 3 && ns[len(ns)-2] == "avro"
+}
+
+func IsXMLFile(name string) bool {
+	if strings.HasSuffix(name, ".xml") {
+		return true
+	}
+	ns := strings.Split(name, ".")
+	return IsCompressedFile(name) && len(ns) >= 3 && ns[len(ns)-2] == "xml"
+}
+
+func IsSQLiteFile(name string) bool {
+	for _, ext := range []string{".sqlite", ".sqlite3", ".db", ".db3"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func IsSQLFile(name string) bool {
+	if strings.HasSuffix(name, ".sql") {
+		return true
+	}
+	ns := strings.Split(name, ".")
+	return IsCompressedFile(name) && len(ns) >= 3 && ns[len(ns)-2] == "sql"
+}
+
+// IsProtobufFile reports whether the file holds length-delimited protobuf messages: .binpb, .pb or .protobin, optionally compressed.
+func IsProtobufFile(name string) bool {
+	ns := strings.Split(TrimCompressionSuffix(name), ".")
+	if len(ns) < 2 {
+		return false
+	}
+	switch ns[len(ns)-1] {
+	case "binpb", "pb", "protobin":
+		return true
+	}
+	return false
+}
+
+func IsLogFile(name string) bool {
+	ns := strings.Split(TrimCompressionSuffix(name), ".")
+	return len(ns) >= 2 && (ns[len(ns)-1] == "log" || ns[len(ns)-1] == "logfmt")
+}
+
+func IsExcelFile(name string) bool {
+	return strings.HasSuffix(name, ".xlsx") || strings.HasSuffix(name, ".xlsm")
 }